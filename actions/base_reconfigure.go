@@ -0,0 +1,10 @@
+package actions
+
+// BaseReconfigure holds the data shared by every reconfigure/remove/reload
+// operation: where Consul lives and what this proxy instance is called.
+type BaseReconfigure struct {
+	ConsulAddresses []string
+	InstanceName    string
+	TemplatesPath   string
+	ConfigsPath     string
+}
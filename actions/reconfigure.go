@@ -0,0 +1,233 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"../metrics"
+	"../proxy"
+)
+
+// frontSuffix and backSuffix name the per-service fragment files combined
+// into the shared haproxy.cfg.
+const frontSuffix = "-fe.cfg"
+const backSuffix = "-be.cfg"
+
+// writeFile, readDir and reloadHAProxy are exposed as variables so tests
+// can replace them with mocks.
+var writeFile = ioutil.WriteFile
+var readDir = ioutil.ReadDir
+var reloadHAProxy = func(args []string) error {
+	cmd := exec.Command("haproxy", args...)
+	return cmd.Run()
+}
+
+// Reconfigurable is implemented by types that can turn service data into
+// HAProxy configuration and reload the running instance.
+type Reconfigurable interface {
+	Execute(args []string) error
+	GetData() (BaseReconfigure, proxy.Service)
+	ReloadAllServices(addresses []string, instanceName, mode, listenerAddress string) error
+	GetTemplates(sr *proxy.Service) (front, back string, err error)
+}
+
+// Reconfigure is the default `Reconfigurable` implementation.
+type Reconfigure struct {
+	BaseReconfigure
+	proxy.Service
+}
+
+// NewReconfigure is exposed as a variable so tests can replace it with a
+// mock constructor. mode is the deployment mode ("service"/"swarm", see
+// Serve.Mode) and has no bearing on serviceData.ReqMode, which callers
+// must set themselves (e.g. via ServiceFromQuery) before constructing.
+var NewReconfigure = func(baseData BaseReconfigure, serviceData proxy.Service, mode string) Reconfigurable {
+	return &Reconfigure{BaseReconfigure: baseData, Service: serviceData}
+}
+
+// Execute generates the front/back templates for the service, writes them
+// alongside the shared haproxy.cfg, and reloads HAProxy.
+func (r *Reconfigure) Execute(args []string) error {
+	start := time.Now()
+	front, back, err := r.GetTemplates(&r.Service)
+	if err == nil {
+		if err = writeServiceTemplates(r.BaseReconfigure, r.Service.ServiceName, front, back); err == nil {
+			err = reloadHAProxy(args)
+		}
+	}
+	metrics.ReloadDurationSeconds.Observe(time.Since(start).Seconds())
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	metrics.ReconfigureTotal.With(map[string]string{
+		"service": r.Service.ServiceName,
+		"result":  result,
+	}).Inc()
+	return err
+}
+
+// GetData returns the base and service data used to build this instance.
+func (r *Reconfigure) GetData() (BaseReconfigure, proxy.Service) {
+	return r.BaseReconfigure, r.Service
+}
+
+// consulKVEntry mirrors a single entry from Consul's
+// `/v1/kv/<prefix>?recurse=true` response.
+type consulKVEntry struct {
+	Key   string
+	Value string
+}
+
+// consulKVGet is exposed as a variable so tests can replace it with a
+// mock. It fetches every KV entry under prefix from the Consul agent at
+// address.
+var consulKVGet = func(address, prefix string) ([]consulKVEntry, error) {
+	resp, err := http.Get(fmt.Sprintf("%s/v1/kv/%s?recurse=true", strings.TrimRight(address, "/"), prefix))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	var entries []consulKVEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// ServicesFromConsul asks Consul (trying each address in turn until one
+// answers) for every service registered under instanceName, parsed the same
+// way the reconfigure HTTP endpoint parses its query string (see
+// ServiceFromQuery). It's exported so other callers (e.g. a
+// discovery.Provider wrapping Consul) can reuse this instead of
+// duplicating the KV parsing.
+func ServicesFromConsul(addresses []string, instanceName string) ([]proxy.Service, error) {
+	var entries []consulKVEntry
+	var err error
+	for _, address := range addresses {
+		if entries, err = consulKVGet(address, instanceName); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return servicesFromConsulKV(instanceName, entries), nil
+}
+
+// ReloadAllServices asks Consul for every service registered under
+// instanceName and reconfigures HAProxy for each of them.
+func (r *Reconfigure) ReloadAllServices(addresses []string, instanceName, mode, listenerAddress string) error {
+	services, err := ServicesFromConsul(addresses, instanceName)
+	if err != nil {
+		return err
+	}
+	for _, svc := range services {
+		reconfigure := NewReconfigure(r.BaseReconfigure, svc, mode)
+		if err := reconfigure.Execute([]string{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// servicesFromConsulKV groups KV entries by the service name in their key
+// (`<instanceName>/<serviceName>/<param>`) and rebuilds each service
+// through ServiceFromQuery, so a Consul-driven reload understands exactly
+// the same parameters (servicePath, port, reqMode, ...) as a reconfigure
+// HTTP request.
+func servicesFromConsulKV(instanceName string, entries []consulKVEntry) []proxy.Service {
+	prefix := instanceName + "/"
+	byService := map[string]url.Values{}
+	order := []string{}
+	for _, entry := range entries {
+		parts := strings.SplitN(strings.TrimPrefix(entry.Key, prefix), "/", 2)
+		if len(parts) != 2 || len(parts[1]) == 0 {
+			continue
+		}
+		serviceName, param := parts[0], parts[1]
+		values, ok := byService[serviceName]
+		if !ok {
+			values = url.Values{}
+			values.Set("serviceName", serviceName)
+			byService[serviceName] = values
+			order = append(order, serviceName)
+		}
+		value, err := base64.StdEncoding.DecodeString(entry.Value)
+		if err != nil {
+			continue
+		}
+		values.Set(param, string(value))
+	}
+	services := make([]proxy.Service, 0, len(order))
+	for _, name := range order {
+		services = append(services, ServiceFromQuery(byService[name]))
+	}
+	return services
+}
+
+// fragmentPaths returns the per-service front/back fragment file paths
+// under base.TemplatesPath.
+func fragmentPaths(base BaseReconfigure, serviceName string) (front, back string) {
+	return filepath.Join(base.TemplatesPath, serviceName+frontSuffix),
+		filepath.Join(base.TemplatesPath, serviceName+backSuffix)
+}
+
+// writeServiceTemplates persists front/back as serviceName's fragment
+// files, then recombines every fragment into the shared haproxy.cfg.
+func writeServiceTemplates(base BaseReconfigure, serviceName, front, back string) error {
+	frontPath, backPath := fragmentPaths(base, serviceName)
+	if err := writeFile(frontPath, []byte(front), 0664); err != nil {
+		return err
+	}
+	if err := writeFile(backPath, []byte(back), 0664); err != nil {
+		return err
+	}
+	return combineTemplates(base)
+}
+
+// removeServiceTemplates deletes serviceName's fragment files, then
+// recombines the remaining fragments into the shared haproxy.cfg.
+func removeServiceTemplates(base BaseReconfigure, serviceName string) error {
+	frontPath, backPath := fragmentPaths(base, serviceName)
+	os.Remove(frontPath)
+	os.Remove(backPath)
+	return combineTemplates(base)
+}
+
+// combineTemplates concatenates every fragment under TemplatesPath into a
+// single ConfigsPath, frontends before backends, since a frontend's
+// `use_backend` clause is only meaningful once its target backend exists.
+func combineTemplates(base BaseReconfigure) error {
+	entries, err := readDir(base.TemplatesPath)
+	if err != nil {
+		return err
+	}
+	var combined bytes.Buffer
+	for _, suffix := range []string{frontSuffix, backSuffix} {
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.HasSuffix(entry.Name(), suffix) {
+				continue
+			}
+			content, err := proxy.ReadFile(filepath.Join(base.TemplatesPath, entry.Name()))
+			if err != nil {
+				return err
+			}
+			combined.Write(content)
+		}
+	}
+	return writeFile(base.ConfigsPath, combined.Bytes(), 0664)
+}
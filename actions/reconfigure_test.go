@@ -0,0 +1,368 @@
+// +build !integration
+
+package actions
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"../metrics"
+	"../proxy"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/suite"
+)
+
+type ReconfigureTestSuite struct {
+	suite.Suite
+}
+
+// Test_NewReconfigure_PreservesServiceReqMode asserts that the deployment
+// mode passed to NewReconfigure (Serve.Mode, e.g. "swarm") never
+// overwrites a ReqMode already carried by the service itself (e.g.
+// "grpc", parsed from the reconfigure request).
+func (s *ReconfigureTestSuite) Test_NewReconfigure_PreservesServiceReqMode() {
+	reconfigure := NewReconfigure(BaseReconfigure{}, proxy.Service{ReqMode: "grpc"}, "swarm")
+
+	_, svc := reconfigure.GetData()
+
+	s.Equal("grpc", svc.ReqMode)
+}
+
+func (s *ReconfigureTestSuite) Test_GetTemplates_WsMode_AddsTunnelTimeoutToBackend() {
+	sr := proxy.Service{
+		ServiceName: "myService",
+		ServiceDest: []proxy.ServiceDest{
+			{Port: "1234", Protocol: "ws"},
+		},
+	}
+	r := Reconfigure{}
+
+	_, back, err := r.GetTemplates(&sr)
+
+	s.NoError(err)
+	s.Contains(back, "timeout tunnel")
+}
+
+// Test_GetTemplates_WsMode_AddsUpgradeAclAndUseBackendToFrontend asserts not
+// just that the websocket upgrade ACL exists, but that it (and the
+// `use_backend` routing it to) lands in the frontend stanza, correctly
+// formatted with the real backend name — `use_backend` is invalid inside a
+// `backend` section, and an unformatted "%[1]s_ws" literal would make
+// HAProxy reject the config outright.
+func (s *ReconfigureTestSuite) Test_GetTemplates_WsMode_AddsUpgradeAclAndUseBackendToFrontend() {
+	sr := proxy.Service{
+		ServiceName: "myService",
+		ServiceDest: []proxy.ServiceDest{
+			{Port: "1234", Protocol: "ws"},
+		},
+	}
+	r := Reconfigure{}
+
+	front, back, err := r.GetTemplates(&sr)
+
+	s.NoError(err)
+	s.Contains(front, "hdr(Upgrade) -i websocket")
+	s.Contains(front, "use_backend myService-be_ws if is_websocket is_websocket_connection")
+	s.NotContains(back, "use_backend")
+	s.NotContains(front, "%[1]s")
+	s.NotContains(back, "%[1]s")
+}
+
+func (s *ReconfigureTestSuite) Test_GetTemplates_H2cMode_AddsProtoH2() {
+	sr := proxy.Service{
+		ServiceName: "myService",
+		ServiceDest: []proxy.ServiceDest{
+			{Port: "1234", Protocol: "h2c"},
+		},
+	}
+	r := Reconfigure{}
+
+	_, back, err := r.GetTemplates(&sr)
+
+	s.NoError(err)
+	s.Contains(back, "proto h2")
+}
+
+func (s *ReconfigureTestSuite) Test_GetTemplates_HttpMode_DoesNotAddTunnelTimeout() {
+	sr := proxy.Service{
+		ServiceName: "myService",
+		ServiceDest: []proxy.ServiceDest{
+			{Port: "1234", Protocol: "http"},
+		},
+	}
+	r := Reconfigure{}
+
+	_, back, err := r.GetTemplates(&sr)
+
+	s.NoError(err)
+	s.NotContains(back, "timeout tunnel")
+}
+
+func (s *ReconfigureTestSuite) Test_GetTemplates_OnlyFromIPs_AddsSrcAcl() {
+	sr := proxy.Service{
+		ServiceName: "myService",
+		ServiceDest: []proxy.ServiceDest{
+			{Port: "1234", OnlyFromIPs: []string{"10.0.0.0/8"}},
+		},
+	}
+	r := Reconfigure{}
+
+	_, back, err := r.GetTemplates(&sr)
+
+	s.NoError(err)
+	s.Contains(back, "acl allowed_src src 10.0.0.0/8")
+	s.Contains(back, "http-request deny unless allowed_src")
+}
+
+// Test_GetTemplates_DenyFromIPs_AddsSrcAcl covers DenyFromIPs on its own,
+// since the only existing coverage combined it with OnlyFromIPs.
+func (s *ReconfigureTestSuite) Test_GetTemplates_DenyFromIPs_AddsSrcAcl() {
+	sr := proxy.Service{
+		ServiceName: "myService",
+		ServiceDest: []proxy.ServiceDest{
+			{Port: "1234", DenyFromIPs: []string{"1.2.3.4/32"}},
+		},
+	}
+	r := Reconfigure{}
+
+	_, back, err := r.GetTemplates(&sr)
+
+	s.NoError(err)
+	s.Contains(back, "acl denied_src src 1.2.3.4/32")
+	s.Contains(back, "http-request deny if denied_src")
+	s.NotContains(back, "allowed_src")
+}
+
+// Test_GetTemplates_TrustedProxyCIDRs_UsesForwardedForHeader asserts that,
+// once TRUSTED_PROXY_CIDRS is configured, the generated ACLs match against
+// X-Forwarded-For instead of the connection's (by-then proxy) source.
+func (s *ReconfigureTestSuite) Test_GetTemplates_TrustedProxyCIDRs_UsesForwardedForHeader() {
+	trustedOrig := os.Getenv("TRUSTED_PROXY_CIDRS")
+	defer os.Setenv("TRUSTED_PROXY_CIDRS", trustedOrig)
+	os.Setenv("TRUSTED_PROXY_CIDRS", "10.0.0.0/8")
+	sr := proxy.Service{
+		ServiceName: "myService",
+		ServiceDest: []proxy.ServiceDest{
+			{Port: "1234", OnlyFromIPs: []string{"192.168.1.0/24"}},
+		},
+	}
+	r := Reconfigure{}
+
+	_, back, err := r.GetTemplates(&sr)
+
+	s.NoError(err)
+	s.Contains(back, "acl allowed_src hdr_ip(X-Forwarded-For) 192.168.1.0/24")
+	s.NotContains(back, "acl allowed_src src ")
+}
+
+func (s *ReconfigureTestSuite) Test_GetTemplates_GrpcMode_AddsProtoH2AndHealthCheck() {
+	sr := proxy.Service{
+		ServiceName: "myService",
+		ReqMode:     "grpc",
+		ServiceDest: []proxy.ServiceDest{
+			{Port: "1234", Protocol: "grpc", ServicePath: []string{"/pkg.Service/Method"}},
+		},
+	}
+	r := Reconfigure{}
+
+	front, back, err := r.GetTemplates(&sr)
+
+	s.NoError(err)
+	s.Contains(back, "proto h2")
+	s.Contains(back, "option httpchk POST /grpc.health.v1.Health/Check")
+	s.Contains(front, "alpn h2,http/1.1")
+	s.Contains(front, "/pkg.Service/Method")
+}
+
+// Test_GetTemplates_GrpcMode_WithoutServiceCert_OmitsSslCrt asserts that a
+// gRPC service registered without a cert doesn't produce an invalid
+// `ssl crt` clause with a blank path.
+func (s *ReconfigureTestSuite) Test_GetTemplates_GrpcMode_WithoutServiceCert_OmitsSslCrt() {
+	sr := proxy.Service{
+		ServiceName: "myService",
+		ReqMode:     "grpc",
+		ServiceDest: []proxy.ServiceDest{
+			{Port: "1234", Protocol: "grpc"},
+		},
+	}
+	r := Reconfigure{}
+
+	front, _, err := r.GetTemplates(&sr)
+
+	s.NoError(err)
+	s.Contains(front, "bind *:443 alpn h2,http/1.1")
+	s.NotContains(front, "ssl crt")
+}
+
+func (s *ReconfigureTestSuite) Test_GetTemplates_GrpcMode_WithGrpcHealthCheckFlag_UsesCheckSslProtoH2() {
+	sr := proxy.Service{
+		ServiceName: "myService",
+		ReqMode:     "grpc",
+		ServiceDest: []proxy.ServiceDest{
+			{Port: "1234", Protocol: "grpc", GRPCHealthCheck: true},
+		},
+	}
+	r := Reconfigure{}
+
+	_, back, err := r.GetTemplates(&sr)
+
+	s.NoError(err)
+	s.Contains(back, "check-ssl proto h2")
+	s.NotContains(back, "grpc.health.v1.Health")
+}
+
+func (s *ReconfigureTestSuite) Test_GetTemplates_GrpcMode_AddsPerMethodRateLimit() {
+	sr := proxy.Service{
+		ServiceName: "myService",
+		ReqMode:     "grpc",
+		ServiceDest: []proxy.ServiceDest{
+			{Port: "1234", Protocol: "grpc", GRPCMethodLimits: map[string]int{"/pkg.Service/Method": 100}},
+		},
+	}
+	r := Reconfigure{}
+
+	_, back, err := r.GetTemplates(&sr)
+
+	s.NoError(err)
+	s.Contains(back, "http_req_rate(10s)")
+	s.Contains(back, "/pkg.Service/Method")
+	s.Contains(back, "gt 100")
+}
+
+// Test_Execute_IncrementsReconfigureTotal asserts that a successful Execute
+// is reflected in the dfp_reconfigure_total counter, labelled by service
+// name and result — the instrumentation PollStats/metrics expose on
+// /metrics.
+func (s *ReconfigureTestSuite) Test_Execute_IncrementsReconfigureTotal() {
+	reloadHAProxyOrig := reloadHAProxy
+	defer func() { reloadHAProxy = reloadHAProxyOrig }()
+	reloadHAProxy = func(args []string) error { return nil }
+	dir := s.T().TempDir()
+	r := Reconfigure{
+		BaseReconfigure: BaseReconfigure{TemplatesPath: dir, ConfigsPath: filepath.Join(dir, "haproxy.cfg")},
+		Service:         proxy.Service{ServiceName: "metricsService"},
+	}
+
+	s.NoError(r.Execute([]string{}))
+
+	m := &dto.Metric{}
+	metrics.ReconfigureTotal.With(map[string]string{
+		"service": "metricsService",
+		"result":  "success",
+	}).Write(m)
+	s.Equal(float64(1), m.GetCounter().GetValue())
+}
+
+// Test_Execute_WritesFragmentsAndCombinesConfig asserts that Execute
+// actually persists the generated templates as per-service fragment files
+// and recombines every fragment into the shared haproxy.cfg, rather than
+// discarding them.
+func (s *ReconfigureTestSuite) Test_Execute_WritesFragmentsAndCombinesConfig() {
+	reloadHAProxyOrig := reloadHAProxy
+	defer func() { reloadHAProxy = reloadHAProxyOrig }()
+	reloadHAProxy = func(args []string) error { return nil }
+	dir := s.T().TempDir()
+	r := Reconfigure{
+		BaseReconfigure: BaseReconfigure{TemplatesPath: dir, ConfigsPath: filepath.Join(dir, "haproxy.cfg")},
+		Service: proxy.Service{
+			ServiceName: "myService",
+			ServiceDest: []proxy.ServiceDest{{Port: "1234"}},
+		},
+	}
+
+	s.NoError(r.Execute([]string{}))
+
+	s.FileExists(filepath.Join(dir, "myService-be.cfg"))
+	combined, err := ioutil.ReadFile(filepath.Join(dir, "haproxy.cfg"))
+	s.NoError(err)
+	s.Contains(string(combined), "backend myService-be")
+}
+
+// Test_Execute_ReturnsError_WhenReloadHAProxyFails asserts that a failing
+// HAProxy reload is surfaced as an Execute error, rather than being
+// swallowed.
+func (s *ReconfigureTestSuite) Test_Execute_ReturnsError_WhenReloadHAProxyFails() {
+	reloadHAProxyOrig := reloadHAProxy
+	defer func() { reloadHAProxy = reloadHAProxyOrig }()
+	reloadHAProxy = func(args []string) error { return fmt.Errorf("this is an error") }
+	dir := s.T().TempDir()
+	r := Reconfigure{
+		BaseReconfigure: BaseReconfigure{TemplatesPath: dir, ConfigsPath: filepath.Join(dir, "haproxy.cfg")},
+		Service:         proxy.Service{ServiceName: "myService"},
+	}
+
+	s.Error(r.Execute([]string{}))
+}
+
+// Test_ReloadAllServices_ReconfiguresEachServiceFromConsulKV asserts that
+// ReloadAllServices actually reads service parameters back from Consul's
+// KV store and reconfigures HAProxy for each one, instead of being a
+// no-op.
+func (s *ReconfigureTestSuite) Test_ReloadAllServices_ReconfiguresEachServiceFromConsulKV() {
+	consulKVGetOrig := consulKVGet
+	defer func() { consulKVGet = consulKVGetOrig }()
+	consulKVGet = func(address, prefix string) ([]consulKVEntry, error) {
+		s.Equal("myInstance", prefix)
+		return []consulKVEntry{
+			{Key: "myInstance/serviceA/port", Value: base64.StdEncoding.EncodeToString([]byte("1234"))},
+			{Key: "myInstance/serviceB/port", Value: base64.StdEncoding.EncodeToString([]byte("5678"))},
+		}, nil
+	}
+	newReconfigureOrig := NewReconfigure
+	defer func() { NewReconfigure = newReconfigureOrig }()
+	var reconfiguredWith []proxy.Service
+	NewReconfigure = func(baseData BaseReconfigure, serviceData proxy.Service, mode string) Reconfigurable {
+		reconfiguredWith = append(reconfiguredWith, serviceData)
+		return &reconfigureMock{}
+	}
+	r := Reconfigure{}
+
+	err := r.ReloadAllServices([]string{"http://consul.example.com"}, "myInstance", "", "")
+
+	s.NoError(err)
+	s.Len(reconfiguredWith, 2)
+	s.Contains(reconfiguredWith, proxy.Service{ServiceName: "serviceA", ServiceDomain: []string{}, ServiceDest: []proxy.ServiceDest{{Port: "1234", ServicePath: []string{}, OnlyFromIPs: []string{}, DenyFromIPs: []string{}, GRPCMethodLimits: map[string]int{}}}})
+	s.Contains(reconfiguredWith, proxy.Service{ServiceName: "serviceB", ServiceDomain: []string{}, ServiceDest: []proxy.ServiceDest{{Port: "5678", ServicePath: []string{}, OnlyFromIPs: []string{}, DenyFromIPs: []string{}, GRPCMethodLimits: map[string]int{}}}})
+}
+
+// Test_ReloadAllServices_TriesNextAddress_WhenFirstFails asserts that a
+// failing Consul address doesn't abort the reload as long as another
+// configured address answers.
+func (s *ReconfigureTestSuite) Test_ReloadAllServices_TriesNextAddress_WhenFirstFails() {
+	consulKVGetOrig := consulKVGet
+	defer func() { consulKVGet = consulKVGetOrig }()
+	consulKVGet = func(address, prefix string) ([]consulKVEntry, error) {
+		if address == "http://down.example.com" {
+			return nil, fmt.Errorf("this is an error")
+		}
+		return nil, nil
+	}
+	r := Reconfigure{}
+
+	err := r.ReloadAllServices([]string{"http://down.example.com", "http://up.example.com"}, "myInstance", "", "")
+
+	s.NoError(err)
+}
+
+// reconfigureMock is a minimal Reconfigurable whose Execute always
+// succeeds, standing in for the real fragment-writing/reload flow.
+type reconfigureMock struct{}
+
+func (m *reconfigureMock) Execute(args []string) error { return nil }
+func (m *reconfigureMock) GetData() (BaseReconfigure, proxy.Service) {
+	return BaseReconfigure{}, proxy.Service{}
+}
+func (m *reconfigureMock) ReloadAllServices(addresses []string, instanceName, mode, listenerAddress string) error {
+	return nil
+}
+func (m *reconfigureMock) GetTemplates(sr *proxy.Service) (front, back string, err error) {
+	return "", "", nil
+}
+
+func TestReconfigureUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(ReconfigureTestSuite))
+}
@@ -0,0 +1,45 @@
+package actions
+
+import (
+	"time"
+
+	"../metrics"
+)
+
+// Removable is implemented by types that can drop a service's HAProxy
+// configuration and reload the running instance.
+type Removable interface {
+	Execute(args []string) error
+}
+
+// Remove deletes a single service's front/back template fragments,
+// recombines the shared haproxy.cfg, and reloads HAProxy.
+type Remove struct {
+	BaseReconfigure
+	ServiceName string
+}
+
+// NewRemove is exposed as a variable so tests can replace it with a mock
+// constructor.
+var NewRemove = func(baseData BaseReconfigure, serviceName string) Removable {
+	return &Remove{BaseReconfigure: baseData, ServiceName: serviceName}
+}
+
+// Execute removes ServiceName's HAProxy configuration and reloads.
+func (r *Remove) Execute(args []string) error {
+	start := time.Now()
+	err := removeServiceTemplates(r.BaseReconfigure, r.ServiceName)
+	if err == nil {
+		err = reloadHAProxy(args)
+	}
+	metrics.ReloadDurationSeconds.Observe(time.Since(start).Seconds())
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	metrics.ReconfigureTotal.With(map[string]string{
+		"service": r.ServiceName,
+		"result":  result,
+	}).Inc()
+	return err
+}
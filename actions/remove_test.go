@@ -0,0 +1,79 @@
+// +build !integration
+
+package actions
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"../metrics"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/suite"
+)
+
+type RemoveTestSuite struct {
+	suite.Suite
+}
+
+// Test_Execute_RemovesFragmentsAndCombinesConfig asserts that Execute
+// deletes a service's fragment files and recombines whatever is left into
+// the shared haproxy.cfg.
+func (s *RemoveTestSuite) Test_Execute_RemovesFragmentsAndCombinesConfig() {
+	reloadHAProxyOrig := reloadHAProxy
+	defer func() { reloadHAProxy = reloadHAProxyOrig }()
+	reloadHAProxy = func(args []string) error { return nil }
+	dir := s.T().TempDir()
+	base := BaseReconfigure{TemplatesPath: dir, ConfigsPath: filepath.Join(dir, "haproxy.cfg")}
+	s.NoError(writeFile(filepath.Join(dir, "myService-be.cfg"), []byte("backend myService-be\n"), 0664))
+	s.NoError(writeFile(filepath.Join(dir, "other-be.cfg"), []byte("backend other-be\n"), 0664))
+	r := Remove{BaseReconfigure: base, ServiceName: "myService"}
+
+	s.NoError(r.Execute([]string{}))
+
+	s.NoFileExists(filepath.Join(dir, "myService-be.cfg"))
+	combined, err := ioutil.ReadFile(filepath.Join(dir, "haproxy.cfg"))
+	s.NoError(err)
+	s.NotContains(string(combined), "myService-be")
+	s.Contains(string(combined), "other-be")
+}
+
+// Test_Execute_ReturnsError_WhenReloadHAProxyFails asserts that a failing
+// HAProxy reload is surfaced as an Execute error.
+func (s *RemoveTestSuite) Test_Execute_ReturnsError_WhenReloadHAProxyFails() {
+	reloadHAProxyOrig := reloadHAProxy
+	defer func() { reloadHAProxy = reloadHAProxyOrig }()
+	reloadHAProxy = func(args []string) error { return fmt.Errorf("this is an error") }
+	dir := s.T().TempDir()
+	r := Remove{BaseReconfigure: BaseReconfigure{TemplatesPath: dir, ConfigsPath: filepath.Join(dir, "haproxy.cfg")}, ServiceName: "myService"}
+
+	s.Error(r.Execute([]string{}))
+}
+
+// Test_Execute_IncrementsReconfigureTotal asserts that a successful remove
+// is reflected in the dfp_reconfigure_total counter, same as a reconfigure,
+// so removal-triggered reloads aren't invisible on /metrics.
+func (s *RemoveTestSuite) Test_Execute_IncrementsReconfigureTotal() {
+	reloadHAProxyOrig := reloadHAProxy
+	defer func() { reloadHAProxy = reloadHAProxyOrig }()
+	reloadHAProxy = func(args []string) error { return nil }
+	dir := s.T().TempDir()
+	r := Remove{
+		BaseReconfigure: BaseReconfigure{TemplatesPath: dir, ConfigsPath: filepath.Join(dir, "haproxy.cfg")},
+		ServiceName:     "removedMetricsService",
+	}
+
+	s.NoError(r.Execute([]string{}))
+
+	m := &dto.Metric{}
+	metrics.ReconfigureTotal.With(map[string]string{
+		"service": "removedMetricsService",
+		"result":  "success",
+	}).Write(m)
+	s.Equal(float64(1), m.GetCounter().GetValue())
+}
+
+func TestRemoveUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(RemoveTestSuite))
+}
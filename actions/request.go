@@ -0,0 +1,59 @@
+package actions
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"../proxy"
+)
+
+// ServiceFromQuery builds a `proxy.Service` (with a single `ServiceDest`)
+// out of the `reconfigure` endpoint's query-string parameters.
+func ServiceFromQuery(query url.Values) proxy.Service {
+	grpcHealthCheck, _ := strconv.ParseBool(query.Get("grpcHealthCheck"))
+	sd := proxy.ServiceDest{
+		ServicePath:      splitNonEmpty(query.Get("servicePath")),
+		Port:             query.Get("port"),
+		Protocol:         query.Get("protocol"),
+		OnlyFromIPs:      splitNonEmpty(query.Get("onlyFromIPs")),
+		DenyFromIPs:      splitNonEmpty(query.Get("denyFromIPs")),
+		GRPCHealthCheck:  grpcHealthCheck,
+		GRPCMethodLimits: grpcMethodLimitsFromQuery(query.Get("grpcMethodLimits")),
+	}
+	return proxy.Service{
+		ServiceName:      query.Get("serviceName"),
+		ServiceColor:     query.Get("serviceColor"),
+		ServiceDomain:    splitNonEmpty(query.Get("serviceDomain")),
+		OutboundHostname: query.Get("outboundHostname"),
+		ReqMode:          query.Get("reqMode"),
+		ServiceDest:      []proxy.ServiceDest{sd},
+	}
+}
+
+func splitNonEmpty(value string) []string {
+	if len(value) == 0 {
+		return []string{}
+	}
+	return strings.Split(value, ",")
+}
+
+// grpcMethodLimitsFromQuery parses the `grpcMethodLimits` query param
+// (e.g. "/pkg.Service/Method=100,/pkg.Service/Other=50") into the
+// per-method rate limits GetTemplates renders into the backend. Pairs
+// that aren't a valid `path=limit` are skipped.
+func grpcMethodLimitsFromQuery(raw string) map[string]int {
+	limits := map[string]int{}
+	for _, pair := range splitNonEmpty(raw) {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		n, err := strconv.Atoi(parts[1])
+		if err != nil {
+			continue
+		}
+		limits[parts[0]] = n
+	}
+	return limits
+}
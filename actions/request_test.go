@@ -0,0 +1,62 @@
+// +build !integration
+
+package actions
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type RequestTestSuite struct {
+	suite.Suite
+}
+
+func (s *RequestTestSuite) Test_ServiceFromQuery_ParsesOnlyFromAndDenyFromIPs() {
+	query := url.Values{}
+	query.Set("serviceName", "myService")
+	query.Set("onlyFromIPs", "10.0.0.0/8,192.168.1.0/24")
+	query.Set("denyFromIPs", "1.2.3.4/32")
+
+	svc := ServiceFromQuery(query)
+
+	s.Equal([]string{"10.0.0.0/8", "192.168.1.0/24"}, svc.ServiceDest[0].OnlyFromIPs)
+	s.Equal([]string{"1.2.3.4/32"}, svc.ServiceDest[0].DenyFromIPs)
+}
+
+// Test_ServiceFromQuery_ParsesProtocolAndReqMode asserts that the
+// reconfigure endpoint can actually request a ws/h2c/grpc backend,
+// instead of protocol/reqMode only being reachable by building a
+// proxy.Service directly in test code.
+func (s *RequestTestSuite) Test_ServiceFromQuery_ParsesProtocolAndReqMode() {
+	query := url.Values{}
+	query.Set("serviceName", "myService")
+	query.Set("protocol", "ws")
+	query.Set("reqMode", "grpc")
+
+	svc := ServiceFromQuery(query)
+
+	s.Equal("grpc", svc.ReqMode)
+	s.Equal("ws", svc.ServiceDest[0].Protocol)
+}
+
+// Test_ServiceFromQuery_ParsesGrpcHealthCheckAndMethodLimits asserts that
+// the gRPC feature set (health-check flag, per-method rate limits) is
+// reachable through the reconfigure endpoint, not only by hand-building a
+// proxy.Service in test code.
+func (s *RequestTestSuite) Test_ServiceFromQuery_ParsesGrpcHealthCheckAndMethodLimits() {
+	query := url.Values{}
+	query.Set("serviceName", "myService")
+	query.Set("grpcHealthCheck", "true")
+	query.Set("grpcMethodLimits", "/pkg.Service/Method=100,/pkg.Service/Other=50")
+
+	svc := ServiceFromQuery(query)
+
+	s.True(svc.ServiceDest[0].GRPCHealthCheck)
+	s.Equal(map[string]int{"/pkg.Service/Method": 100, "/pkg.Service/Other": 50}, svc.ServiceDest[0].GRPCMethodLimits)
+}
+
+func TestRequestUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(RequestTestSuite))
+}
@@ -0,0 +1,145 @@
+package actions
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"../proxy"
+)
+
+// backendProtocolSnippet returns the extra `backend` lines needed to proxy
+// sd according to its Protocol. The default ("http", "") needs nothing
+// extra: plain HTTP/1.1 keep-alive is HAProxy's default behaviour.
+func backendProtocolSnippet(sd proxy.ServiceDest) string {
+	var buf bytes.Buffer
+	switch sd.Protocol {
+	case "ws":
+		buf.WriteString("    option http-server-close\n")
+		buf.WriteString("    timeout tunnel 3600s\n")
+	case "h2c":
+		buf.WriteString("    server-template srv 1 check proto h2\n")
+	}
+	return buf.String()
+}
+
+// wsFrontendSnippet returns the `frontend` ACL and `use_backend` lines that
+// route upgrade requests for backendName to its dedicated `_ws` backend.
+// `use_backend` is only valid in frontend/listen sections, so this must
+// never be appended to a `backend` stanza.
+func wsFrontendSnippet(backendName string, sd proxy.ServiceDest) string {
+	var buf bytes.Buffer
+	buf.WriteString("    acl is_websocket hdr(Upgrade) -i websocket\n")
+	buf.WriteString("    acl is_websocket_connection hdr(Connection) -i upgrade\n")
+	buf.WriteString(fmt.Sprintf("    use_backend %s_ws if is_websocket is_websocket_connection\n", backendName))
+	return buf.String()
+}
+
+// srcMatcher is the ACL fetch used to identify the client's address: the
+// connection's source by default, or X-Forwarded-For once a trusted-proxy
+// list is configured (TRUSTED_PROXY_CIDRS), since by then `src` is the
+// load balancer's address rather than the real client's.
+func srcMatcher() string {
+	if trusted := os.Getenv("TRUSTED_PROXY_CIDRS"); len(trusted) > 0 {
+		return "hdr_ip(X-Forwarded-For)"
+	}
+	return "src"
+}
+
+// ipACLSnippet returns the `acl`/`http-request deny` lines that restrict sd
+// to OnlyFromIPs and/or block DenyFromIPs.
+func ipACLSnippet(sd proxy.ServiceDest) string {
+	if len(sd.OnlyFromIPs) == 0 && len(sd.DenyFromIPs) == 0 {
+		return ""
+	}
+
+	match := srcMatcher()
+	var buf bytes.Buffer
+	if len(sd.OnlyFromIPs) > 0 {
+		buf.WriteString(fmt.Sprintf("    acl allowed_src %s %s\n", match, strings.Join(sd.OnlyFromIPs, " ")))
+		buf.WriteString("    http-request deny unless allowed_src\n")
+	}
+	if len(sd.DenyFromIPs) > 0 {
+		buf.WriteString(fmt.Sprintf("    acl denied_src %s %s\n", match, strings.Join(sd.DenyFromIPs, " ")))
+		buf.WriteString("    http-request deny if denied_src\n")
+	}
+	return buf.String()
+}
+
+// grpcHealthCheckSnippet returns the `backend` server-template and
+// health-check lines for a gRPC service: a gRPC health-checking-protocol
+// probe by default, or a bare `check-ssl proto h2` when
+// `grpcHealthCheck=true` signals the backend doesn't implement it. This
+// replaces (rather than supplements) backendProtocolSnippet's generic
+// `server-template` line so the two don't collide.
+func grpcHealthCheckSnippet(sd proxy.ServiceDest) string {
+	if sd.GRPCHealthCheck {
+		return "    server-template srv 1 check-ssl proto h2 verify none\n"
+	}
+	return "    server-template srv 1 check proto h2\n" +
+		"    option httpchk POST /grpc.health.v1.Health/Check\n" +
+		"    http-check expect status 200\n"
+}
+
+// grpcMethodLimitsSnippet rate-limits individual gRPC methods using a
+// per-method stick-table tracking the request rate on the method's path.
+func grpcMethodLimitsSnippet(sd proxy.ServiceDest) string {
+	if len(sd.GRPCMethodLimits) == 0 {
+		return ""
+	}
+	var buf bytes.Buffer
+	buf.WriteString("    stick-table type string len 128 size 1m expire 10s store http_req_rate(10s)\n")
+	buf.WriteString("    http-request track-sc0 path\n")
+	for method, limit := range sd.GRPCMethodLimits {
+		buf.WriteString(fmt.Sprintf(
+			"    http-request deny deny_status 429 if { path %s } { sc_http_req_rate(0) gt %d }\n",
+			method, limit,
+		))
+	}
+	return buf.String()
+}
+
+// grpcFrontendSnippet binds the frontend once for ALPN-negotiated HTTP/2
+// (falling back to HTTP/1.1), then routes each of the service's gRPC
+// full-method paths to its backend.
+func grpcFrontendSnippet(sr *proxy.Service) string {
+	var buf bytes.Buffer
+	if len(sr.ServiceCert) > 0 {
+		buf.WriteString(fmt.Sprintf("    bind *:443 alpn h2,http/1.1 ssl crt %s\n", sr.ServiceCert))
+	} else {
+		buf.WriteString("    bind *:443 alpn h2,http/1.1\n")
+	}
+	for _, sd := range sr.ServiceDest {
+		for _, path := range sd.ServicePath {
+			buf.WriteString(fmt.Sprintf(
+				"    use_backend %s-be if { path %s }\n", sr.ServiceName, path,
+			))
+		}
+	}
+	return buf.String()
+}
+
+// GetTemplates renders the `frontend` and `backend` HAProxy stanzas for a
+// single service.
+func (r *Reconfigure) GetTemplates(sr *proxy.Service) (front, back string, err error) {
+	var frontBuf, backBuf bytes.Buffer
+	if sr.ReqMode == "grpc" {
+		frontBuf.WriteString(grpcFrontendSnippet(sr))
+	}
+	for _, sd := range sr.ServiceDest {
+		backendName := fmt.Sprintf("%s-be", sr.ServiceName)
+		backBuf.WriteString(fmt.Sprintf("backend %s\n", backendName))
+		if sr.ReqMode == "grpc" {
+			backBuf.WriteString(grpcHealthCheckSnippet(sd))
+			backBuf.WriteString(grpcMethodLimitsSnippet(sd))
+		} else {
+			backBuf.WriteString(backendProtocolSnippet(sd))
+		}
+		if sd.Protocol == "ws" {
+			frontBuf.WriteString(wsFrontendSnippet(backendName, sd))
+		}
+		backBuf.WriteString(ipACLSnippet(sd))
+	}
+	return frontBuf.String(), backBuf.String(), nil
+}
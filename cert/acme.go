@@ -0,0 +1,230 @@
+package cert
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// DNSProvider solves a DNS-01 challenge by publishing a TXT record for
+// domain and tearing it down once the challenge is done.
+type DNSProvider interface {
+	Present(domain, token, keyAuth string) error
+	CleanUp(domain, token, keyAuth string) error
+}
+
+// ACMEManager issues and renews certificates through ACME (e.g. Let's
+// Encrypt), storing the result the same way a manually uploaded
+// certificate is stored.
+type ACMEManager struct {
+	cert *Cert
+
+	// Email is passed to the ACME CA when registering an account.
+	Email string
+	// CAURL is the ACME directory URL (defaults to Let's Encrypt's
+	// production endpoint when empty).
+	CAURL string
+	// DNSProvider, when set, solves challenges via DNS-01 instead of
+	// HTTP-01.
+	DNSProvider DNSProvider
+	// OnRenew is invoked after a certificate is (re)issued, so the
+	// caller can reload HAProxy. Reconfigure/Execute is wired in here by
+	// the server.
+	OnRenew func() error
+
+	manager *autocert.Manager
+}
+
+// acmeClient is the subset of *acme.Client's API the DNS-01 flow uses. It
+// exists so tests can swap in a fake ACME server's client instead of
+// dialing a real CA.
+type acmeClient interface {
+	Register(ctx context.Context, a *acme.Account, prompt func(tosURL string) bool) (*acme.Account, error)
+	AuthorizeOrder(ctx context.Context, id []acme.AuthzID, opt ...acme.OrderOption) (*acme.Order, error)
+	GetAuthorization(ctx context.Context, url string) (*acme.Authorization, error)
+	DNS01ChallengeRecord(token string) (string, error)
+	Accept(ctx context.Context, chal *acme.Challenge) (*acme.Challenge, error)
+	WaitAuthorization(ctx context.Context, url string) (*acme.Authorization, error)
+	CreateOrderCert(ctx context.Context, url string, csr []byte, bundle bool) (der [][]byte, certURL string, err error)
+}
+
+// newACMEClient is exposed as a variable so tests can replace it with a
+// fake CA's client.
+var newACMEClient = func(caURL string, key crypto.Signer) acmeClient {
+	return &acme.Client{DirectoryURL: caURL, Key: key}
+}
+
+// NewACMEManager builds an ACMEManager storing its state (account keys and
+// issued certs) under the cert directory already used by Cert.
+func NewACMEManager(c *Cert, email, caURL string) *ACMEManager {
+	m := &ACMEManager{cert: c, Email: email, CAURL: caURL}
+	m.manager = &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Email:      email,
+		Cache:      autocert.DirCache(c.CertsDir),
+		HostPolicy: autocert.HostWhitelist(),
+	}
+	if len(caURL) > 0 {
+		m.manager.Client = &acme.Client{DirectoryURL: caURL}
+	}
+	return m
+}
+
+// HTTPHandler wraps fallback with the `/.well-known/acme-challenge/`
+// handler HTTP-01 validation needs while a challenge is pending.
+func (m *ACMEManager) HTTPHandler(fallback http.Handler) http.Handler {
+	return m.manager.HTTPHandler(fallback)
+}
+
+// EnsureACME issues or renews certificates for domains, stores each one
+// through PutCert, and triggers OnRenew so HAProxy picks up the change.
+// Domains are validated via DNS-01 when DNSProvider is set, otherwise via
+// autocert's HTTP-01 flow (served through HTTPHandler).
+func (c *Cert) EnsureACME(domains []string) error {
+	c.ensureACMEManager()
+	c.acme.manager.HostPolicy = autocert.HostWhitelist(domains...)
+
+	issue := c.issueViaHTTP01
+	if c.acme.DNSProvider != nil {
+		issue = c.issueViaDNS01
+	}
+
+	for _, domain := range domains {
+		content, err := issue(domain)
+		if err != nil {
+			return err
+		}
+		if _, err := c.PutCert(domain+".pem", content); err != nil {
+			return err
+		}
+	}
+
+	if c.acme.OnRenew != nil {
+		return c.acme.OnRenew()
+	}
+	return nil
+}
+
+// issueViaHTTP01 relies on autocert's own HTTP-01 flow: GetCertificate
+// blocks until the fallback handler mounted through HTTPHandler has served
+// the challenge autocert placed under /.well-known/acme-challenge/.
+func (c *Cert) issueViaHTTP01(domain string) ([]byte, error) {
+	hello := &tls.ClientHelloInfo{ServerName: domain}
+	certificate, err := c.acme.manager.GetCertificate(hello)
+	if err != nil {
+		return nil, err
+	}
+	return certEncodePEM(certificate.Certificate, certificate.PrivateKey)
+}
+
+// issueViaDNS01 drives the ACME v2 order flow directly — autocert only
+// implements HTTP-01/TLS-ALPN-01 — publishing each challenge's TXT record
+// through DNSProvider.Present and tearing it down once the authorization
+// is accepted.
+func (c *Cert) issueViaDNS01(domain string) ([]byte, error) {
+	ctx := context.Background()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	client := newACMEClient(c.acme.CAURL, key)
+
+	account := &acme.Account{Contact: []string{"mailto:" + c.acme.Email}}
+	if _, err := client.Register(ctx, account, acme.AcceptTOS); err != nil && err != acme.ErrAccountAlreadyExists {
+		return nil, err
+	}
+
+	order, err := client.AuthorizeOrder(ctx, []acme.AuthzID{{Type: "dns", Value: domain}})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, authzURL := range order.AuthzURLs {
+		if err := c.acceptDNS01Challenge(ctx, client, authzURL, domain); err != nil {
+			return nil, err
+		}
+	}
+
+	csrKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{DNSNames: []string{domain}}, csrKey)
+	if err != nil {
+		return nil, err
+	}
+
+	der, _, err := client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return nil, err
+	}
+	return certEncodePEM(der, csrKey)
+}
+
+// acceptDNS01Challenge finds the dns-01 challenge in the authorization at
+// authzURL, publishes its TXT record through DNSProvider, accepts it and
+// waits for the CA to validate it.
+func (c *Cert) acceptDNS01Challenge(ctx context.Context, client acmeClient, authzURL, domain string) error {
+	authz, err := client.GetAuthorization(ctx, authzURL)
+	if err != nil {
+		return err
+	}
+	var chal *acme.Challenge
+	for _, candidate := range authz.Challenges {
+		if candidate.Type == "dns-01" {
+			chal = candidate
+			break
+		}
+	}
+	if chal == nil {
+		return fmt.Errorf("cert: no dns-01 challenge offered for %s", domain)
+	}
+
+	keyAuth, err := client.DNS01ChallengeRecord(chal.Token)
+	if err != nil {
+		return err
+	}
+	if err := c.acme.DNSProvider.Present(domain, chal.Token, keyAuth); err != nil {
+		return err
+	}
+	defer c.acme.DNSProvider.CleanUp(domain, chal.Token, keyAuth)
+
+	if _, err := client.Accept(ctx, chal); err != nil {
+		return err
+	}
+	_, err = client.WaitAuthorization(ctx, authzURL)
+	return err
+}
+
+// certEncodePEM renders chain (leaf first, then any intermediates) and key
+// as a single PEM document: one `CERTIFICATE` block per chain entry
+// followed by a `PRIVATE KEY` block. That's the layout HAProxy's
+// `bind ... crt` expects, and what PutCert stores under CertsDir.
+func certEncodePEM(chain [][]byte, key interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, der := range chain {
+		if err := pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: der}); err != nil {
+			return nil, err
+		}
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := pem.Encode(&buf, &pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,138 @@
+package cert
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"../metrics"
+	"../proxy"
+	"../server"
+)
+
+// writeFile and readDir are exposed as variables so tests can replace them
+// with mocks.
+var writeFile = ioutil.WriteFile
+var readDir = ioutil.ReadDir
+
+// Certer is implemented by types that manage the certificates used by
+// HAProxy's SSL frontends.
+type Certer interface {
+	Put(w http.ResponseWriter, req *http.Request) (string, error)
+	PutCert(certName string, certContent []byte) (string, error)
+	GetAll(w http.ResponseWriter, req *http.Request) (server.CertResponse, error)
+	Init() error
+	// EnsureACME issues (or renews) certificates for domains via ACME,
+	// storing the result through PutCert and reloading HAProxy.
+	EnsureACME(domains []string) error
+	// HTTPHandler wraps fallback with the ACME HTTP-01 challenge responder,
+	// so issuance/renewal requests triggered by EnsureACME can be validated.
+	HTTPHandler(fallback http.Handler) http.Handler
+	// SetOnRenew registers the callback EnsureACME invokes after a
+	// successful issuance/renewal, so the caller can reload HAProxy.
+	SetOnRenew(onRenew func() error)
+}
+
+// Cert is the default `Certer` implementation. Certificates are stored as
+// individual files under CertsDir.
+type Cert struct {
+	CertsDir string
+
+	acme *ACMEManager
+}
+
+// NewCert creates a new `Cert` rooted at certsDir.
+func NewCert(certsDir string) *Cert {
+	return &Cert{CertsDir: certsDir}
+}
+
+// Put stores the certificate carried in the request body on disk and
+// returns its file name. The name is the request's raw query string (e.g.
+// `PUT .../cert?my-domain.com.pem`).
+func (c *Cert) Put(w http.ResponseWriter, req *http.Request) (string, error) {
+	content, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return "", err
+	}
+	return c.PutCert(req.URL.RawQuery, content)
+}
+
+// PutCert writes certContent to certName under CertsDir.
+func (c *Cert) PutCert(certName string, certContent []byte) (string, error) {
+	err := writeFile(filepath.Join(c.CertsDir, certName), certContent, 0664)
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	metrics.CertsTotal.With(map[string]string{"result": result}).Inc()
+	return certName, err
+}
+
+// GetAll returns every certificate currently stored under CertsDir.
+func (c *Cert) GetAll(w http.ResponseWriter, req *http.Request) (server.CertResponse, error) {
+	entries, err := readDir(c.CertsDir)
+	if err != nil {
+		return server.CertResponse{}, err
+	}
+	certs := map[string]string{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		content, err := proxy.ReadFile(filepath.Join(c.CertsDir, entry.Name()))
+		if err != nil {
+			return server.CertResponse{}, err
+		}
+		certs[entry.Name()] = string(content)
+	}
+	return server.CertResponse{Certs: certs}, nil
+}
+
+// Init creates CertsDir if it doesn't already exist, then persists any
+// certificates provided through the `CERTS` env var (comma-separated
+// `name=content` pairs) so they're available before the first request.
+func (c *Cert) Init() error {
+	if err := os.MkdirAll(c.CertsDir, 0755); err != nil {
+		return err
+	}
+	raw := os.Getenv("CERTS")
+	if len(raw) == 0 {
+		return nil
+	}
+	for _, pair := range strings.Split(raw, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		if _, err := c.PutCert(parts[0], []byte(parts[1])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HTTPHandler wraps fallback with the ACME HTTP-01 challenge responder,
+// lazily creating the ACME manager the first time it's called.
+func (c *Cert) HTTPHandler(fallback http.Handler) http.Handler {
+	return c.ensureACMEManager().HTTPHandler(fallback)
+}
+
+// SetOnRenew registers the callback EnsureACME invokes after a successful
+// issuance/renewal, lazily creating the ACME manager the first time it's
+// called.
+func (c *Cert) SetOnRenew(onRenew func() error) {
+	c.ensureACMEManager().OnRenew = onRenew
+}
+
+// ensureACMEManager returns the Cert's ACMEManager, creating it from the
+// ACME_EMAIL/ACME_CA_URL env vars the first time it's needed.
+func (c *Cert) ensureACMEManager() *ACMEManager {
+	if c.acme == nil {
+		email := os.Getenv("ACME_EMAIL")
+		caURL := os.Getenv("ACME_CA_URL")
+		c.acme = NewACMEManager(c, email, caURL)
+	}
+	return c.acme
+}
@@ -0,0 +1,79 @@
+// +build !integration
+
+package cert
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type CertTestSuite struct {
+	suite.Suite
+}
+
+// Test_PutCert_WritesContentUnderCertsDir asserts that PutCert actually
+// persists certContent to disk, instead of discarding it.
+func (s *CertTestSuite) Test_PutCert_WritesContentUnderCertsDir() {
+	c := NewCert(s.T().TempDir())
+
+	name, err := c.PutCert("my-domain.com.pem", []byte("fake-cert"))
+
+	s.NoError(err)
+	s.Equal("my-domain.com.pem", name)
+	content, err := ioutil.ReadFile(c.CertsDir + "/my-domain.com.pem")
+	s.NoError(err)
+	s.Equal("fake-cert", string(content))
+}
+
+// Test_Put_ReadsBodyAndStoresItUnderTheRawQueryName asserts that Put reads
+// the request body and stores it via PutCert under the cert name carried
+// as the request's raw query string.
+func (s *CertTestSuite) Test_Put_ReadsBodyAndStoresItUnderTheRawQueryName() {
+	c := NewCert(s.T().TempDir())
+	req := httptest.NewRequest("PUT", "/cert?my-domain.com.pem", strings.NewReader("fake-cert"))
+
+	name, err := c.Put(httptest.NewRecorder(), req)
+
+	s.NoError(err)
+	s.Equal("my-domain.com.pem", name)
+	content, err := ioutil.ReadFile(c.CertsDir + "/my-domain.com.pem")
+	s.NoError(err)
+	s.Equal("fake-cert", string(content))
+}
+
+// Test_GetAll_ReturnsEveryStoredCertificate asserts that GetAll reads back
+// every certificate previously written under CertsDir.
+func (s *CertTestSuite) Test_GetAll_ReturnsEveryStoredCertificate() {
+	c := NewCert(s.T().TempDir())
+	_, err := c.PutCert("my-domain.com.pem", []byte("fake-cert"))
+	s.Require().NoError(err)
+
+	resp, err := c.GetAll(httptest.NewRecorder(), httptest.NewRequest("GET", "/certs", nil))
+
+	s.NoError(err)
+	s.Equal("fake-cert", resp.Certs["my-domain.com.pem"])
+}
+
+// Test_Init_LoadsCertsEnvVar asserts that Init persists certificates
+// provided through the `CERTS` env var before the first request.
+func (s *CertTestSuite) Test_Init_LoadsCertsEnvVar() {
+	origCerts := os.Getenv("CERTS")
+	defer os.Setenv("CERTS", origCerts)
+	os.Setenv("CERTS", "my-domain.com.pem=fake-cert")
+	c := NewCert(s.T().TempDir() + "/nested")
+
+	s.NoError(c.Init())
+
+	content, err := ioutil.ReadFile(c.CertsDir + "/my-domain.com.pem")
+	s.NoError(err)
+	s.Equal("fake-cert", string(content))
+}
+
+func TestCertUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(CertTestSuite))
+}
@@ -0,0 +1,58 @@
+package discovery
+
+import (
+	"context"
+	"time"
+
+	"../actions"
+	"../proxy"
+)
+
+// ConsulProvider discovers services registered in Consul's KV store under
+// InstanceName, the same source the legacy ReloadAllServices flow reads
+// from — letting Consul participate in the provider subsystem (merge,
+// Watch) instead of only being reachable through that separate path.
+type ConsulProvider struct {
+	Addresses    []string
+	InstanceName string
+	PollInterval time.Duration
+}
+
+// NewConsulProvider creates a `ConsulProvider` for the given Consul
+// addresses and instance name, polling every 30 seconds unless overridden.
+func NewConsulProvider(addresses []string, instanceName string) *ConsulProvider {
+	return &ConsulProvider{Addresses: addresses, InstanceName: instanceName, PollInterval: 30 * time.Second}
+}
+
+// List returns every service currently registered under InstanceName.
+func (p *ConsulProvider) List() ([]proxy.Service, error) {
+	return actions.ServicesFromConsul(p.Addresses, p.InstanceName)
+}
+
+// Events polls Consul every PollInterval, emitting an update per registered
+// service and a removed event for any service that has dropped out of
+// Consul since the last poll.
+func (p *ConsulProvider) Events(ctx context.Context) <-chan ProviderID {
+	out := make(chan ProviderID)
+	go func() {
+		defer close(out)
+		ticker := time.NewTicker(p.PollInterval)
+		defer ticker.Stop()
+		var tracker changeTracker
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				services, err := p.List()
+				if err != nil {
+					continue
+				}
+				for _, id := range tracker.diff(services) {
+					out <- id
+				}
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,75 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"../proxy"
+)
+
+// DNSProvider discovers services from SRV records under a single domain,
+// e.g. `_myservice._tcp.example.com`.
+type DNSProvider struct {
+	Domain       string
+	PollInterval time.Duration
+}
+
+// NewDNSProvider creates a `DNSProvider` resolving SRV records under
+// domain, polling every 30 seconds unless overridden.
+func NewDNSProvider(domain string) *DNSProvider {
+	return &DNSProvider{Domain: domain, PollInterval: 30 * time.Second}
+}
+
+// lookupSRV is exposed as a variable so tests can replace it with a mock.
+var lookupSRV = net.LookupSRV
+
+// List resolves the SRV records for the configured domain into services,
+// one per target so that a domain resolving to several hosts doesn't
+// collapse into a single arbitrary survivor when Merge dedups by name.
+func (p *DNSProvider) List() ([]proxy.Service, error) {
+	_, srvs, err := lookupSRV("", "", p.Domain)
+	if err != nil {
+		return nil, err
+	}
+	services := []proxy.Service{}
+	for _, srv := range srvs {
+		target := strings.TrimSuffix(srv.Target, ".")
+		services = append(services, proxy.Service{
+			ServiceName:      p.Domain + "-" + target,
+			OutboundHostname: target,
+			ServiceDest:      []proxy.ServiceDest{{Port: strconv.Itoa(int(srv.Port))}},
+		})
+	}
+	return services, nil
+}
+
+// Events polls the SRV records every PollInterval, emitting an update per
+// resolved target and a removed event for any target that has dropped out
+// of the SRV answer since the last poll.
+func (p *DNSProvider) Events(ctx context.Context) <-chan ProviderID {
+	out := make(chan ProviderID)
+	go func() {
+		defer close(out)
+		ticker := time.NewTicker(p.PollInterval)
+		defer ticker.Stop()
+		var tracker changeTracker
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				services, err := p.List()
+				if err != nil {
+					continue
+				}
+				for _, id := range tracker.diff(services) {
+					out <- id
+				}
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,47 @@
+// +build !integration
+
+package discovery
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type DNSTestSuite struct {
+	suite.Suite
+}
+
+func (s *DNSTestSuite) SetupTest() {
+	lookupSRV = net.LookupSRV
+}
+
+// Test_List_GivesEachTargetADistinctServiceName asserts that a domain
+// resolving to several SRV targets produces one service per target, each
+// with its own name and port, instead of every target sharing the
+// domain's name and colliding in Merge's by-name dedup.
+func (s *DNSTestSuite) Test_List_GivesEachTargetADistinctServiceName() {
+	lookupSRV = func(service, proto, name string) (string, []*net.SRV, error) {
+		s.Equal("my-service.example.com", name)
+		return "", []*net.SRV{
+			{Target: "host-a.example.com.", Port: 1234},
+			{Target: "host-b.example.com.", Port: 5678},
+		}, nil
+	}
+	p := NewDNSProvider("my-service.example.com")
+
+	services, err := p.List()
+
+	s.NoError(err)
+	s.Len(services, 2)
+	s.NotEqual(services[0].ServiceName, services[1].ServiceName)
+	s.Equal("host-a.example.com", services[0].OutboundHostname)
+	s.Equal("1234", services[0].ServiceDest[0].Port)
+	s.Equal("host-b.example.com", services[1].OutboundHostname)
+	s.Equal("5678", services[1].ServiceDest[0].Port)
+}
+
+func TestDNSUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(DNSTestSuite))
+}
@@ -0,0 +1,126 @@
+package discovery
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"../actions"
+	"../proxy"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+)
+
+// dockerLabelPrefix marks the services this provider cares about.
+const dockerLabelPrefix = "com.df."
+
+// DockerProvider discovers services from Docker Engine events, filtering
+// on containers/services labelled `com.df.*`.
+type DockerProvider struct {
+	Host   string
+	client *client.Client
+}
+
+// NewDockerProvider creates a `DockerProvider` talking to the Docker
+// daemon at host (empty string uses the default from the environment).
+func NewDockerProvider(host string) *DockerProvider {
+	return &DockerProvider{Host: host}
+}
+
+func (p *DockerProvider) dockerClient() (*client.Client, error) {
+	if p.client != nil {
+		return p.client, nil
+	}
+	var cli *client.Client
+	var err error
+	if len(p.Host) > 0 {
+		cli, err = client.NewClientWithOpts(client.FromEnv, client.WithHost(p.Host))
+	} else {
+		cli, err = client.NewClientWithOpts(client.FromEnv)
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.client = cli
+	return cli, nil
+}
+
+// serviceFromLabels builds a `proxy.Service` out of a container/event's
+// `com.df.*` labels, reusing `actions.ServiceFromQuery` so this provider
+// understands exactly the same parameters (port, servicePath, reqMode, ...)
+// as the reconfigure HTTP endpoint. It reports false if labels aren't
+// tagged with `com.df.serviceName` at all.
+func serviceFromLabels(labels map[string]string) (proxy.Service, bool) {
+	if _, ok := labels[dockerLabelPrefix+"serviceName"]; !ok {
+		return proxy.Service{}, false
+	}
+	values := url.Values{}
+	for key, value := range labels {
+		if !strings.HasPrefix(key, dockerLabelPrefix) {
+			continue
+		}
+		values.Set(strings.TrimPrefix(key, dockerLabelPrefix), value)
+	}
+	return actions.ServiceFromQuery(values), true
+}
+
+// List returns one `proxy.Service` per running container/service labelled
+// with `com.df.*`.
+func (p *DockerProvider) List() ([]proxy.Service, error) {
+	cli, err := p.dockerClient()
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	containers, err := cli.ContainerList(ctx, types.ContainerListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	services := []proxy.Service{}
+	for _, c := range containers {
+		svc, ok := serviceFromLabels(c.Labels)
+		if !ok {
+			continue
+		}
+		services = append(services, svc)
+	}
+	return services, nil
+}
+
+// Events streams Docker container start/stop events for labelled
+// containers, translating them into provider events.
+func (p *DockerProvider) Events(ctx context.Context) <-chan ProviderID {
+	out := make(chan ProviderID)
+	go func() {
+		defer close(out)
+		cli, err := p.dockerClient()
+		if err != nil {
+			return
+		}
+		f := filters.NewArgs()
+		f.Add("label", dockerLabelPrefix+"serviceName")
+		msgs, errs := cli.Events(ctx, types.EventsOptions{Filters: f})
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case err := <-errs:
+				if err != nil {
+					return
+				}
+			case msg := <-msgs:
+				svc, ok := serviceFromLabels(msg.Actor.Attributes)
+				if !ok {
+					continue
+				}
+				eventType := EventUpdated
+				if msg.Action == "die" || msg.Action == "stop" {
+					eventType = EventRemoved
+				}
+				out <- ProviderID{Type: eventType, Service: svc}
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,45 @@
+// +build !integration
+
+package discovery
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type DockerTestSuite struct {
+	suite.Suite
+}
+
+// Test_ServiceFromLabels_ParsesBeyondServiceName asserts that a container's
+// `com.df.*` labels are understood the same way the reconfigure HTTP
+// endpoint understands its query string, instead of only `serviceName`
+// surviving and everything else (port, path, ...) being dropped.
+func (s *DockerTestSuite) Test_ServiceFromLabels_ParsesBeyondServiceName() {
+	svc, ok := serviceFromLabels(map[string]string{
+		"com.df.serviceName": "my-service",
+		"com.df.port":        "1234",
+		"com.df.servicePath": "/api,/api2",
+		"com.df.reqMode":     "grpc",
+		"unrelated.label":    "ignored",
+	})
+
+	s.True(ok)
+	s.Equal("my-service", svc.ServiceName)
+	s.Equal("grpc", svc.ReqMode)
+	s.Equal("1234", svc.ServiceDest[0].Port)
+	s.Equal([]string{"/api", "/api2"}, svc.ServiceDest[0].ServicePath)
+}
+
+// Test_ServiceFromLabels_RequiresServiceName asserts that containers not
+// labelled with `com.df.serviceName` are skipped.
+func (s *DockerTestSuite) Test_ServiceFromLabels_RequiresServiceName() {
+	_, ok := serviceFromLabels(map[string]string{"com.df.port": "1234"})
+
+	s.False(ok)
+}
+
+func TestDockerUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(DockerTestSuite))
+}
@@ -0,0 +1,70 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+
+	"../proxy"
+	"github.com/fsnotify/fsnotify"
+)
+
+// FileProvider discovers services from a JSON/YAML file, re-reading it
+// whenever fsnotify reports a change.
+type FileProvider struct {
+	Path string
+}
+
+// NewFileProvider creates a `FileProvider` watching path.
+func NewFileProvider(path string) *FileProvider {
+	return &FileProvider{Path: path}
+}
+
+// List parses the configured file into a slice of services.
+func (p *FileProvider) List() ([]proxy.Service, error) {
+	data, err := ioutil.ReadFile(p.Path)
+	if err != nil {
+		return nil, err
+	}
+	var services []proxy.Service
+	if err := json.Unmarshal(data, &services); err != nil {
+		return nil, err
+	}
+	return services, nil
+}
+
+// Events watches the file and emits an update event per service every time
+// it changes, plus a removed event for any service that has dropped out of
+// the file since the last change.
+func (p *FileProvider) Events(ctx context.Context) <-chan ProviderID {
+	out := make(chan ProviderID)
+	go func() {
+		defer close(out)
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			return
+		}
+		defer watcher.Close()
+		watcher.Add(p.Path)
+
+		var tracker changeTracker
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event := <-watcher.Events:
+				if event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+					continue
+				}
+				services, err := p.List()
+				if err != nil {
+					continue
+				}
+				for _, id := range tracker.diff(services) {
+					out <- id
+				}
+			}
+		}
+	}()
+	return out
+}
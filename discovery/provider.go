@@ -0,0 +1,84 @@
+// Package discovery defines the provider interface used to discover
+// services that should be proxied, plus the built-in providers (consul,
+// file, docker, dns).
+package discovery
+
+import (
+	"context"
+
+	"../proxy"
+)
+
+// EventType describes what happened to a service.
+type EventType string
+
+const (
+	// EventUpdated is emitted when a service is created or changed.
+	EventUpdated EventType = "updated"
+	// EventRemoved is emitted when a service should be removed.
+	EventRemoved EventType = "removed"
+)
+
+// ProviderID identifies a single service change reported by a Provider.
+type ProviderID struct {
+	Type    EventType
+	Service proxy.Service
+}
+
+// Provider is implemented by every service-discovery backend named in
+// `PROVIDERS`. Consul also has a separate, always-on legacy path
+// (ReloadAllServices) independent of this subsystem.
+type Provider interface {
+	// Events streams service changes until ctx is cancelled.
+	Events(ctx context.Context) <-chan ProviderID
+	// List returns every service currently known to this provider.
+	List() ([]proxy.Service, error)
+}
+
+// NewProviders builds the list of providers named in `names` (as found in
+// the `PROVIDERS` env var, e.g. "consul,file,docker,dns"). consulAddresses
+// and instanceName configure the `consul` provider the same way the
+// legacy ReloadAllServices flow is configured.
+func NewProviders(names []string, consulAddresses []string, instanceName string) []Provider {
+	providers := []Provider{}
+	for _, name := range names {
+		switch name {
+		case "consul":
+			providers = append(providers, NewConsulProvider(consulAddresses, instanceName))
+		case "file":
+			providers = append(providers, NewFileProvider(""))
+		case "docker":
+			providers = append(providers, NewDockerProvider(""))
+		case "dns":
+			providers = append(providers, NewDNSProvider(""))
+		}
+	}
+	return providers
+}
+
+// changeTracker turns successive List() snapshots into ProviderID events,
+// emitting EventRemoved for services that vanish between polls (List alone
+// has no way to signal removal, so a poll-based Events must diff them
+// itself).
+type changeTracker struct {
+	seen map[string]bool
+}
+
+// diff returns an EventUpdated for every service in current, plus an
+// EventRemoved for every service name seen on a previous call that is
+// missing from current.
+func (c *changeTracker) diff(current []proxy.Service) []ProviderID {
+	now := map[string]bool{}
+	events := make([]ProviderID, 0, len(current))
+	for _, svc := range current {
+		now[svc.ServiceName] = true
+		events = append(events, ProviderID{Type: EventUpdated, Service: svc})
+	}
+	for name := range c.seen {
+		if !now[name] {
+			events = append(events, ProviderID{Type: EventRemoved, Service: proxy.Service{ServiceName: name}})
+		}
+	}
+	c.seen = now
+	return events
+}
@@ -0,0 +1,123 @@
+// +build !integration
+
+package discovery
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"../proxy"
+	"github.com/stretchr/testify/suite"
+)
+
+type ProviderTestSuite struct {
+	suite.Suite
+}
+
+// eventsProvider is a minimal Provider whose Events channel replays a
+// fixed set of events, then closes.
+type eventsProvider struct {
+	events []ProviderID
+}
+
+func (p *eventsProvider) List() ([]proxy.Service, error) {
+	return nil, nil
+}
+
+func (p *eventsProvider) Events(ctx context.Context) <-chan ProviderID {
+	out := make(chan ProviderID, len(p.events))
+	for _, id := range p.events {
+		out <- id
+	}
+	close(out)
+	return out
+}
+
+// Test_Watch_FansInEventsFromEveryProvider asserts that Watch invokes
+// onEvent for every event reported by every provider, not just the first
+// one.
+func (s *ProviderTestSuite) Test_Watch_FansInEventsFromEveryProvider() {
+	a := &eventsProvider{events: []ProviderID{{Type: EventUpdated, Service: proxy.Service{ServiceName: "svc-a"}}}}
+	b := &eventsProvider{events: []ProviderID{{Type: EventRemoved, Service: proxy.Service{ServiceName: "svc-b"}}}}
+	var mu sync.Mutex
+	var received []ProviderID
+
+	Watch(context.Background(), []Provider{a, b}, func(id ProviderID) {
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, id)
+	})
+
+	s.Len(received, 2)
+}
+
+// Test_Merge_DedupesAcrossProviders exercises the merge matrix: consul,
+// file, docker and dns providers reporting overlapping and distinct
+// services should end up deduped by service name.
+func (s *ProviderTestSuite) Test_Merge_DedupesAcrossProviders() {
+	consul := []proxy.Service{{ServiceName: "svc-a"}}
+	file := []proxy.Service{{ServiceName: "svc-a", OutboundHostname: "from-file"}, {ServiceName: "svc-b"}}
+	docker := []proxy.Service{{ServiceName: "svc-c"}}
+	dns := []proxy.Service{}
+
+	merged := Merge(consul, file, docker, dns)
+
+	s.Len(merged, 3)
+	names := []string{}
+	for _, svc := range merged {
+		names = append(names, svc.ServiceName)
+	}
+	s.Contains(names, "svc-a")
+	s.Contains(names, "svc-b")
+	s.Contains(names, "svc-c")
+}
+
+func (s *ProviderTestSuite) Test_Merge_LaterProviderWinsOnConflict() {
+	consul := []proxy.Service{{ServiceName: "svc-a", OutboundHostname: "from-consul"}}
+	file := []proxy.Service{{ServiceName: "svc-a", OutboundHostname: "from-file"}}
+
+	merged := Merge(consul, file)
+
+	s.Len(merged, 1)
+	s.Equal("from-file", merged[0].OutboundHostname)
+}
+
+// Test_NewProviders_BuildsConsulProvider asserts that "consul" in the
+// PROVIDERS env var actually produces a discovery.Provider (wired with the
+// same addresses/instance name as the legacy ReloadAllServices flow),
+// instead of being silently dropped by the name switch.
+func (s *ProviderTestSuite) Test_NewProviders_BuildsConsulProvider() {
+	providers := NewProviders([]string{"consul", "file", "docker", "dns"}, []string{"http://consul.example.com"}, "myInstance")
+
+	s.Len(providers, 4)
+	consul, ok := providers[0].(*ConsulProvider)
+	s.Require().True(ok, "expected the first provider to be a *ConsulProvider, got %T", providers[0])
+	s.Equal([]string{"http://consul.example.com"}, consul.Addresses)
+	s.Equal("myInstance", consul.InstanceName)
+}
+
+// Test_ChangeTracker_Diff_EmitsRemovedForVanishedServices asserts that a
+// service present in one poll and missing from the next produces an
+// EventRemoved, not silence — the gap that left poll-based providers
+// (file, dns, consul) unable to ever clean up a removed service.
+func (s *ProviderTestSuite) Test_ChangeTracker_Diff_EmitsRemovedForVanishedServices() {
+	var tracker changeTracker
+
+	first := tracker.diff([]proxy.Service{{ServiceName: "svc-a"}, {ServiceName: "svc-b"}})
+	s.Len(first, 2)
+
+	second := tracker.diff([]proxy.Service{{ServiceName: "svc-a"}})
+
+	s.Len(second, 2)
+	byType := map[EventType][]string{}
+	for _, id := range second {
+		byType[id.Type] = append(byType[id.Type], id.Service.ServiceName)
+	}
+	s.Equal([]string{"svc-a"}, byType[EventUpdated])
+	s.Equal([]string{"svc-b"}, byType[EventRemoved])
+}
+
+func TestProviderUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(ProviderTestSuite))
+}
@@ -0,0 +1,48 @@
+package discovery
+
+import (
+	"context"
+	"sync"
+
+	"../proxy"
+)
+
+// Watch fans in every provider's Events channel and invokes onEvent for
+// each one as it arrives, so the caller can react to containers/files/DNS
+// records changing after startup instead of only reading List() once. It
+// blocks until every provider's Events channel has closed; the built-in
+// providers close theirs once ctx is cancelled.
+func Watch(ctx context.Context, providers []Provider, onEvent func(ProviderID)) {
+	var wg sync.WaitGroup
+	for _, p := range providers {
+		wg.Add(1)
+		go func(p Provider) {
+			defer wg.Done()
+			for id := range p.Events(ctx) {
+				onEvent(id)
+			}
+		}(p)
+	}
+	wg.Wait()
+}
+
+// Merge combines the service lists reported by several providers into one,
+// deduplicating by service name. Later lists win on conflict, so the order
+// providers are passed in doubles as their priority.
+func Merge(lists ...[]proxy.Service) []proxy.Service {
+	byName := map[string]proxy.Service{}
+	order := []string{}
+	for _, list := range lists {
+		for _, svc := range list {
+			if _, ok := byName[svc.ServiceName]; !ok {
+				order = append(order, svc.ServiceName)
+			}
+			byName[svc.ServiceName] = svc
+		}
+	}
+	merged := make([]proxy.Service, 0, len(order))
+	for _, name := range order {
+		merged = append(merged, byName[name])
+	}
+	return merged
+}
@@ -0,0 +1,84 @@
+// Package metrics exposes the Prometheus instrumentation for
+// docker-flow-proxy's own operations (reconfigure/remove/certs) and for
+// HAProxy's backends, parsed from its stats socket.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ReconfigureTotal counts reconfigure attempts by service and result
+// ("success" or "failure").
+var ReconfigureTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "dfp_reconfigure_total",
+		Help: "Total number of reconfigure operations, by service and result.",
+	},
+	[]string{"service", "result"},
+)
+
+// ReloadDurationSeconds measures how long a HAProxy reload takes.
+var ReloadDurationSeconds = prometheus.NewHistogram(
+	prometheus.HistogramOpts{
+		Name: "dfp_reload_duration_seconds",
+		Help: "Duration of HAProxy reloads triggered by reconfigure/remove.",
+	},
+)
+
+// CertsTotal counts certificate operations by result.
+var CertsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "dfp_certs_total",
+		Help: "Total number of certificate operations, by result.",
+	},
+	[]string{"result"},
+)
+
+// BackendSessions reports the current session count for a backend server,
+// as parsed from HAProxy's stats socket.
+var BackendSessions = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "dfp_backend_sessions",
+		Help: "Current number of sessions on a backend server.",
+	},
+	[]string{"backend", "server"},
+)
+
+// BackendBytesIn reports cumulative bytes received by a backend server.
+var BackendBytesIn = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "dfp_backend_bytes_in",
+		Help: "Cumulative bytes received by a backend server.",
+	},
+	[]string{"backend", "server"},
+)
+
+// BackendBytesOut reports cumulative bytes sent by a backend server.
+var BackendBytesOut = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "dfp_backend_bytes_out",
+		Help: "Cumulative bytes sent by a backend server.",
+	},
+	[]string{"backend", "server"},
+)
+
+// BackendHealth reports 1 when a backend server is UP, 0 otherwise.
+var BackendHealth = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "dfp_backend_health",
+		Help: "1 if the backend server is healthy (UP), 0 otherwise.",
+	},
+	[]string{"backend", "server"},
+)
+
+func init() {
+	prometheus.MustRegister(
+		ReconfigureTotal,
+		ReloadDurationSeconds,
+		CertsTotal,
+		BackendSessions,
+		BackendBytesIn,
+		BackendBytesOut,
+		BackendHealth,
+	)
+}
@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// These constants index the fields of HAProxy's `show stat` CSV output that
+// this package cares about. See HAProxy's management doc, section 9.1.
+const (
+	colPxName  = 0
+	colSvName  = 1
+	colSCur    = 4
+	colBin     = 8
+	colBout    = 9
+	colStatus  = 17
+)
+
+// ParseAndExportStats parses the CSV produced by HAProxy's `show stat`
+// admin-socket command and updates the per-backend gauges.
+func ParseAndExportStats(csv string) {
+	scanner := bufio.NewScanner(strings.NewReader(csv))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, ",")
+		if len(fields) <= colStatus {
+			continue
+		}
+		pxname := fields[colPxName]
+		svname := fields[colSvName]
+		if svname == "FRONTEND" {
+			continue
+		}
+
+		labels := map[string]string{"backend": pxname, "server": svname}
+		if v, err := strconv.ParseFloat(fields[colSCur], 64); err == nil {
+			BackendSessions.With(labels).Set(v)
+		}
+		if v, err := strconv.ParseFloat(fields[colBin], 64); err == nil {
+			BackendBytesIn.With(labels).Set(v)
+		}
+		if v, err := strconv.ParseFloat(fields[colBout], 64); err == nil {
+			BackendBytesOut.With(labels).Set(v)
+		}
+		health := 0.0
+		if strings.HasPrefix(fields[colStatus], "UP") {
+			health = 1.0
+		}
+		BackendHealth.With(labels).Set(health)
+	}
+}
+
+// StatsReader fetches the raw `show stat` CSV from HAProxy's admin socket.
+type StatsReader func() (string, error)
+
+// DialStatsSocket returns a StatsReader that opens HAProxy's admin socket at
+// path, issues a `show stat` command and returns its CSV response.
+func DialStatsSocket(path string) StatsReader {
+	return func() (string, error) {
+		conn, err := net.Dial("unix", path)
+		if err != nil {
+			return "", err
+		}
+		defer conn.Close()
+		if _, err := conn.Write([]byte("show stat\n")); err != nil {
+			return "", err
+		}
+		out, err := ioutil.ReadAll(conn)
+		if err != nil {
+			return "", err
+		}
+		return string(out), nil
+	}
+}
+
+// PollStats calls read every interval and exports the result until stop is
+// closed.
+func PollStats(read StatsReader, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			csv, err := read()
+			if err != nil {
+				continue
+			}
+			ParseAndExportStats(csv)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+// +build !integration
+
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/suite"
+)
+
+const sampleStats = `# pxname,svname,qcur,qmax,scur,smax,slim,stot,bin,bout,dreq,dresp,ereq,econ,eresp,wretr,wredis,status,weight,act,bck,chkfail,chkdown,lastchg,downtime,qlimit,pid,iid,sid,throttle,lbtot,tracked,type,rate,rate_lim,rate_max,check_status,check_code,check_duration,hrsp_1xx,hrsp_2xx,hrsp_3xx,hrsp_4xx,hrsp_5xx,hrsp_other,hanafail,req_rate,req_rate_max,req_tot,cli_abrt,srv_abrt,comp_in,comp_out,comp_byp,comp_rsp,lastsess,last_chk,last_agt,qtime,ctime,rtime,ttime
+myService-be,FRONTEND,0,0,2,5,1000,100,2000,3000,0,0,0,,,,,OPEN,,,,,,,,,1,1,0,,,,0,1,0,1,,,,1,99,0,0,0,0,,1,1,100,,,0,0,0,,,,,,,,
+myService-be,srv-1,0,0,3,5,,100,2000,3000,,0,,0,0,0,0,UP,1,1,0,0,0,12,0,,1,1,1,,100,,2,0,,1,L7OK,200,0,1,99,0,0,0,0,,0,1,100,0,0,,,,,0,L7OK/200,,0,0,0,0
+`
+
+type StatsSocketTestSuite struct {
+	suite.Suite
+}
+
+func (s *StatsSocketTestSuite) metric(vec *prometheus.GaugeVec, labels map[string]string) float64 {
+	m := &dto.Metric{}
+	vec.With(labels).Write(m)
+	return m.GetGauge().GetValue()
+}
+
+func (s *StatsSocketTestSuite) Test_ParseAndExportStats_SkipsFrontendRow() {
+	ParseAndExportStats(sampleStats)
+
+	labels := map[string]string{"backend": "myService-be", "server": "srv-1"}
+	s.Equal(float64(3), s.metric(BackendSessions, labels))
+	s.Equal(float64(2000), s.metric(BackendBytesIn, labels))
+	s.Equal(float64(3000), s.metric(BackendBytesOut, labels))
+	s.Equal(float64(1), s.metric(BackendHealth, labels))
+	s.False(BackendSessions.DeleteLabelValues("myService-be", "FRONTEND"))
+}
+
+func (s *StatsSocketTestSuite) Test_ParseAndExportStats_MarksDownServerUnhealthy() {
+	down := "# pxname,svname,qcur,qmax,scur,smax,slim,stot,bin,bout,dreq,dresp,ereq,econ,eresp,wretr,wredis,status\n" +
+		"myService-be,srv-2,0,0,0,0,,0,0,0,,0,,0,0,0,0,DOWN\n"
+
+	ParseAndExportStats(down)
+
+	labels := map[string]string{"backend": "myService-be", "server": "srv-2"}
+	s.Equal(float64(0), s.metric(BackendHealth, labels))
+}
+
+func TestStatsSocketUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(StatsSocketTestSuite))
+}
@@ -0,0 +1,72 @@
+package proxy
+
+import "io/ioutil"
+
+// ReadFile is a wrapper around ioutil.ReadFile exposed as a variable so that
+// it can be mocked out in tests.
+var ReadFile = ioutil.ReadFile
+
+// Service holds the data needed to reconfigure HAProxy for a single
+// Dockerflow-managed service.
+type Service struct {
+	ServiceName       string
+	ServiceColor      string
+	ServiceDomain     []string
+	ServiceCert       string
+	OutboundHostname  string
+	PathType          string
+	ReqMode           string
+	Users             []User
+	ServiceDest       []ServiceDest
+}
+
+// User holds basic-auth credentials for a service.
+type User struct {
+	Username string
+	Password string
+}
+
+// ServiceDest models a single path/port destination that is proxied towards
+// a backend. A `Service` can have multiple `ServiceDest` entries (e.g. one
+// per exposed path).
+type ServiceDest struct {
+	Port             string
+	ServicePath      []string
+	SrcPort          int
+	HttpsPort        int
+	ReqMode          string
+	// Protocol selects the backend wiring HAProxy generates for this
+	// destination. Supported values are "http" (the default), "ws",
+	// "h2c" and "grpc".
+	Protocol string
+	// OnlyFromIPs, when non-empty, restricts this destination to the
+	// listed CIDRs; everything else is denied.
+	OnlyFromIPs []string
+	// DenyFromIPs, when non-empty, blocks the listed CIDRs; everything
+	// else is allowed.
+	DenyFromIPs []string
+	// GRPCHealthCheck switches the gRPC health check from the default
+	// `option httpchk POST /grpc.health.v1.Health/Check` to a
+	// `check-ssl proto h2` probe, for backends that only speak gRPC over
+	// TLS.
+	GRPCHealthCheck bool
+	// GRPCMethodLimits caps the request rate (requests per 10-second
+	// window) of individual gRPC methods, keyed by their full path (e.g.
+	// "/pkg.Service/Method").
+	GRPCMethodLimits map[string]int
+}
+
+// IsWebsocket reports whether this destination should be proxied as a
+// WebSocket upgrade.
+func (sd *ServiceDest) IsWebsocket() bool {
+	return sd.Protocol == "ws"
+}
+
+// IsHTTP2 reports whether this destination should be proxied as cleartext
+// HTTP/2 (h2c), talking `proto h2` to the backend. Protocol "grpc" is not
+// included here: gRPC routing is driven entirely by Service.ReqMode (see
+// GetTemplates), with Protocol "grpc" only a per-dest marker consumed
+// alongside it, not an independent signal.
+func (sd *ServiceDest) IsHTTP2() bool {
+	return sd.Protocol == "h2c"
+}
@@ -0,0 +1,24 @@
+package main
+
+import "os/exec"
+
+// Executable is implemented by anything that can be run as a standalone
+// CLI command (as opposed to the long-running `Serve` command).
+type Executable interface {
+	Execute(args []string) error
+}
+
+// Run starts (or reloads) the HAProxy process itself.
+type Run struct{}
+
+// NewRun is exposed as a variable so tests can replace it with a mock
+// constructor.
+var NewRun = func() Executable {
+	return Run{}
+}
+
+// Execute runs the haproxy binary with args.
+func (r Run) Execute(args []string) error {
+	cmd := exec.Command("haproxy", args...)
+	return cmd.Run()
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"./actions"
+	certpkg "./cert"
+	"./discovery"
+	"./metrics"
+	"./proxy"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const configsPath = "/cfg/haproxy.cfg"
+
+var logPrintf = log.Printf
+var httpListenAndServe = http.ListenAndServe
+var lookupHost = net.LookupHost
+var readFile = proxy.ReadFile
+var usersBasePath = "/run/secrets/dfp_users_%s"
+var httpWriterSetContentType = func(w http.ResponseWriter, value string) {
+	w.Header().Set("Content-Type", value)
+}
+
+var cert = certpkg.Certer(certpkg.NewCert("/certs"))
+
+// statsSocketPath is where HAProxy's admin socket lives; PollStats reads
+// `show stat` output from it to populate the per-backend gauges.
+var statsSocketPath = "/var/run/haproxy.sock"
+var newStatsReader = metrics.DialStatsSocket
+var pollStats = metrics.PollStats
+var statsPollInterval = 5 * time.Second
+
+// Serve is the long-running `serve` command: it starts HAProxy, exposes the
+// reconfigure/remove/cert HTTP API, and (re)loads every service already
+// registered in Consul.
+type Serve struct {
+	actions.BaseReconfigure
+	IP              string
+	Port            string
+	Mode            string
+	ListenerAddress string
+	// Providers lists the service-discovery backends to merge, as named in
+	// the `PROVIDERS` env var (e.g. "consul,file,docker,dns").
+	Providers []discovery.Provider
+}
+
+var serverImpl = Serve{}
+
+// Execute starts HAProxy, reloads every known service and then blocks
+// serving the HTTP API.
+func (s *Serve) Execute(args []string) error {
+	if addresses := os.Getenv("CONSUL_ADDRESS"); len(addresses) > 0 {
+		s.ConsulAddresses = []string{}
+		for _, a := range strings.Split(addresses, ",") {
+			if !strings.Contains(a, "://") {
+				a = fmt.Sprintf("http://%s", a)
+			}
+			s.ConsulAddresses = append(s.ConsulAddresses, a)
+		}
+	} else {
+		s.ConsulAddresses = []string{}
+	}
+
+	if names := os.Getenv("PROVIDERS"); len(names) > 0 {
+		s.Providers = discovery.NewProviders(strings.Split(names, ","), s.ConsulAddresses, s.InstanceName)
+	}
+
+	if err := cert.Init(); err != nil {
+		return err
+	}
+	cert.SetOnRenew(func() error {
+		reconfigure := actions.NewReconfigure(s.BaseReconfigure, proxy.Service{}, "")
+		return reconfigure.ReloadAllServices(s.ConsulAddresses, s.InstanceName, s.Mode, "")
+	})
+
+	if err := NewRun().Execute(args); err != nil {
+		return err
+	}
+
+	go pollStats(newStatsReader(statsSocketPath), statsPollInterval, make(chan struct{}))
+
+	if !strings.EqualFold(s.Mode, "service") && !strings.EqualFold(s.Mode, "swarm") {
+		listenerAddress := ""
+		if len(s.ListenerAddress) > 0 {
+			listenerAddress = fmt.Sprintf("http://%s:8080", s.ListenerAddress)
+		}
+		reconfigure := actions.NewReconfigure(s.BaseReconfigure, proxy.Service{}, "")
+		if err := reconfigure.ReloadAllServices(s.ConsulAddresses, s.InstanceName, s.Mode, listenerAddress); err != nil {
+			return err
+		}
+		if err := s.reloadFromProviders(); err != nil {
+			return err
+		}
+		s.watchProviders()
+	}
+
+	addr := fmt.Sprintf("%s:%s", s.IP, s.Port)
+	return httpListenAndServe(addr, cert.HTTPHandler(s))
+}
+
+// reloadFromProviders merges the service lists reported by every
+// configured discovery provider and reconfigures HAProxy for each merged
+// service using its own discovered data (name, destinations, ports).
+func (s *Serve) reloadFromProviders() error {
+	if len(s.Providers) == 0 {
+		return nil
+	}
+	lists := make([][]proxy.Service, 0, len(s.Providers))
+	for _, p := range s.Providers {
+		list, err := p.List()
+		if err != nil {
+			logPrintf("ERROR: %s", err.Error())
+			continue
+		}
+		lists = append(lists, list)
+	}
+	for _, svc := range discovery.Merge(lists...) {
+		reconfigure := actions.NewReconfigure(s.BaseReconfigure, svc, s.Mode)
+		if err := reconfigure.Execute([]string{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// watchProviders fans in every provider's Events channel in the
+// background, so a container starting/stopping, a config file changing or
+// a DNS record updating reaches HAProxy without waiting for the next
+// reload, rather than only being picked up once at startup.
+func (s *Serve) watchProviders() {
+	if len(s.Providers) == 0 {
+		return
+	}
+	go discovery.Watch(context.Background(), s.Providers, s.reconfigureFromEvent)
+}
+
+// reconfigureFromEvent routes a single provider event through the same
+// reconfigure/remove flow the HTTP API uses.
+func (s *Serve) reconfigureFromEvent(id discovery.ProviderID) {
+	if id.Type == discovery.EventRemoved {
+		remove := actions.NewRemove(s.BaseReconfigure, id.Service.ServiceName)
+		if err := remove.Execute([]string{}); err != nil {
+			logPrintf("ERROR: %s", err.Error())
+		}
+		return
+	}
+	reconfigure := actions.NewReconfigure(s.BaseReconfigure, id.Service, s.Mode)
+	if err := reconfigure.Execute([]string{}); err != nil {
+		logPrintf("ERROR: %s", err.Error())
+	}
+}
+
+// ServeHTTP dispatches requests to the reconfigure/remove/config/cert
+// handlers based on the request path.
+func (s *Serve) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch {
+	case strings.HasSuffix(req.URL.Path, "/reconfigure"):
+		s.ReconfigureHandler(w, req)
+	case strings.HasSuffix(req.URL.Path, "/remove"):
+		s.RemoveHandler(w, req)
+	case strings.HasSuffix(req.URL.Path, "/config"):
+		s.ConfigHandler(w, req)
+	case strings.HasSuffix(req.URL.Path, "/metrics"):
+		s.MetricsHandler(w, req)
+	case strings.HasSuffix(req.URL.Path, "/certs"):
+		s.CertsHandler(w, req)
+	case strings.HasSuffix(req.URL.Path, "/acme"):
+		s.AcmeHandler(w, req)
+	case strings.Contains(req.URL.Path, "/cert"):
+		s.CertPutHandler(w, req)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// ReconfigureHandler parses a service out of the reconfigure query string
+// and reconfigures HAProxy for it.
+func (s *Serve) ReconfigureHandler(w http.ResponseWriter, req *http.Request) {
+	service := actions.ServiceFromQuery(req.URL.Query())
+	reconfigure := actions.NewReconfigure(s.BaseReconfigure, service, s.Mode)
+	if err := reconfigure.Execute([]string{}); err != nil {
+		logPrintf("ERROR: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// RemoveHandler drops the service named by the `serviceName` query param.
+func (s *Serve) RemoveHandler(w http.ResponseWriter, req *http.Request) {
+	remove := actions.NewRemove(s.BaseReconfigure, req.URL.Query().Get("serviceName"))
+	if err := remove.Execute([]string{}); err != nil {
+		logPrintf("ERROR: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// MetricsHandler exposes docker-flow-proxy's own operation counters and
+// HAProxy's per-backend stats in Prometheus text format.
+func (s *Serve) MetricsHandler(w http.ResponseWriter, req *http.Request) {
+	promhttp.Handler().ServeHTTP(w, req)
+}
+
+// ConfigHandler returns the current haproxy.cfg contents.
+func (s *Serve) ConfigHandler(w http.ResponseWriter, req *http.Request) {
+	httpWriterSetContentType(w, "text/html")
+	content, err := proxy.ReadFile(configsPath)
+	if err != nil {
+		logPrintf("ERROR: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Write(content)
+}
+
+// CertsHandler returns every certificate currently stored.
+func (s *Serve) CertsHandler(w http.ResponseWriter, req *http.Request) {
+	resp, err := cert.GetAll(w, req)
+	if err != nil {
+		logPrintf("ERROR: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	httpWriterSetContentType(w, "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// CertPutHandler stores a certificate uploaded in the request body.
+func (s *Serve) CertPutHandler(w http.ResponseWriter, req *http.Request) {
+	if _, err := cert.Put(w, req); err != nil {
+		logPrintf("ERROR: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// AcmeHandler triggers on-demand issuance (or renewal) of the certificates
+// listed in the `domain` query param, falling back to `ACME_DOMAINS` when
+// it is absent.
+func (s *Serve) AcmeHandler(w http.ResponseWriter, req *http.Request) {
+	domains := req.URL.Query()["domain"]
+	if len(domains) == 0 {
+		domains = strings.Split(os.Getenv("ACME_DOMAINS"), ",")
+	}
+	if err := cert.EnsureACME(domains); err != nil {
+		logPrintf("ERROR: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
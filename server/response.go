@@ -0,0 +1,7 @@
+package server
+
+// CertResponse is the payload returned by the `certs` endpoint: a map of
+// certificate file name to its PEM-encoded content.
+type CertResponse struct {
+	Certs map[string]string `json:"certs"`
+}
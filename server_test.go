@@ -3,6 +3,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -11,6 +12,8 @@ import (
 	"testing"
 
 	"./actions"
+	"./discovery"
+	"./metrics"
 	"./proxy"
 	"./server"
 	"github.com/stretchr/testify/mock"
@@ -42,6 +45,8 @@ type ServerTestSuite struct {
 func (s *ServerTestSuite) SetupTest() {
 	s.sd = proxy.ServiceDest{
 		ServicePath: []string{"/path/to/my/service/api", "/path/to/my/other/service/api"},
+		OnlyFromIPs: []string{"10.0.0.0/8", "192.168.1.0/24"},
+		DenyFromIPs: []string{"1.2.3.4/32"},
 	}
 	s.Service.ServiceDest = []proxy.ServiceDest{s.sd}
 	s.InstanceName = "proxy-test-instance"
@@ -54,13 +59,15 @@ func (s *ServerTestSuite) SetupTest() {
 	s.ReconfigureBaseUrl = fmt.Sprintf("%s/reconfigure", s.BaseUrl)
 	s.RemoveBaseUrl = fmt.Sprintf("%s/remove", s.BaseUrl)
 	s.ReconfigureUrl = fmt.Sprintf(
-		"%s?serviceName=%s&serviceColor=%s&servicePath=%s&serviceDomain=%s&outboundHostname=%s",
+		"%s?serviceName=%s&serviceColor=%s&servicePath=%s&serviceDomain=%s&outboundHostname=%s&onlyFromIPs=%s&denyFromIPs=%s",
 		s.ReconfigureBaseUrl,
 		s.ServiceName,
 		s.ServiceColor,
 		strings.Join(s.sd.ServicePath, ","),
 		strings.Join(s.ServiceDomain, ","),
 		s.OutboundHostname,
+		strings.Join(s.sd.OnlyFromIPs, ","),
+		strings.Join(s.sd.DenyFromIPs, ","),
 	)
 	s.ReqMode = "http"
 	s.RemoveUrl = fmt.Sprintf("%s?serviceName=%s", s.RemoveBaseUrl, s.ServiceName)
@@ -74,6 +81,10 @@ func (s *ServerTestSuite) SetupTest() {
 	httpListenAndServe = func(addr string, handler http.Handler) error {
 		return nil
 	}
+	pollStats = func(read metrics.StatsReader, interval time.Duration, stop <-chan struct{}) {}
+	NewRun = func() Executable {
+		return getRunMock("")
+	}
 	serverImpl = Serve{
 		BaseReconfigure: actions.BaseReconfigure{
 			ConsulAddresses: []string{s.ConsulAddress},
@@ -83,6 +94,9 @@ func (s *ServerTestSuite) SetupTest() {
 	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
 		return getReconfigureMock("")
 	}
+	actions.NewRemove = func(baseData actions.BaseReconfigure, serviceName string) actions.Removable {
+		return getRemoveMock("")
+	}
 	logPrintfOrig := logPrintf
 	defer func() { logPrintf = logPrintfOrig }()
 	logPrintf = func(format string, v ...interface{}) {}
@@ -171,6 +185,89 @@ func (s *ServerTestSuite) Test_Execute_InvokesReloadAllServices() {
 	mockObj.AssertCalled(s.T(), "ReloadAllServices", []string{s.ConsulAddress}, s.InstanceName, "", "")
 }
 
+// Test_Execute_ReloadsFromEachProvider exercises a matrix of discovery
+// providers (file, docker, dns), mocking each and asserting that Execute
+// reconfigures HAProxy using that provider's own discovered service data
+// rather than an empty placeholder.
+func (s *ServerTestSuite) Test_Execute_ReloadsFromEachProvider() {
+	providerNames := []string{"file", "docker", "dns"}
+	for _, name := range providerNames {
+		expected := proxy.Service{ServiceName: "svc-from-" + name}
+		provider := &providerMock{services: []proxy.Service{expected}}
+
+		var reconfiguredWith []proxy.Service
+		actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
+			reconfiguredWith = append(reconfiguredWith, serviceData)
+			return getReconfigureMock("")
+		}
+
+		srv := Serve{Providers: []discovery.Provider{provider}}
+		srv.Execute([]string{})
+
+		s.Contains(reconfiguredWith, expected, "provider %q: expected its discovered service to reach NewReconfigure", name)
+	}
+}
+
+// Test_Execute_ReloadsMergedStateAcrossProviders asserts that services
+// discovered by several providers at once are deduped by name before being
+// reconfigured.
+func (s *ServerTestSuite) Test_Execute_ReloadsMergedStateAcrossProviders() {
+	file := &providerMock{services: []proxy.Service{{ServiceName: "svc-a"}, {ServiceName: "svc-b"}}}
+	docker := &providerMock{services: []proxy.Service{{ServiceName: "svc-a", OutboundHostname: "from-docker"}}}
+
+	var reconfiguredWith []proxy.Service
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
+		reconfiguredWith = append(reconfiguredWith, serviceData)
+		return getReconfigureMock("")
+	}
+
+	srv := Serve{Providers: []discovery.Provider{file, docker}}
+	srv.Execute([]string{})
+
+	named := []proxy.Service{}
+	for _, svc := range reconfiguredWith {
+		if len(svc.ServiceName) > 0 {
+			named = append(named, svc)
+		}
+	}
+	s.Len(named, 2)
+	s.Contains(named, proxy.Service{ServiceName: "svc-a", OutboundHostname: "from-docker"})
+	s.Contains(named, proxy.Service{ServiceName: "svc-b"})
+}
+
+// Test_ReconfigureFromEvent_ReconfiguresOnUpdate asserts that an updated
+// provider event (a container starting, a file/DNS record changing)
+// reaches HAProxy the same way a reconfigure HTTP request would, instead
+// of only being picked up on the next full reload.
+func (s *ServerTestSuite) Test_ReconfigureFromEvent_ReconfiguresOnUpdate() {
+	var reconfiguredWith proxy.Service
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
+		reconfiguredWith = serviceData
+		return getReconfigureMock("")
+	}
+	srv := Serve{}
+
+	srv.reconfigureFromEvent(discovery.ProviderID{Type: discovery.EventUpdated, Service: proxy.Service{ServiceName: "svc-a"}})
+
+	s.Equal("svc-a", reconfiguredWith.ServiceName)
+}
+
+// Test_ReconfigureFromEvent_RemovesOnRemoved asserts that a removed
+// provider event drops the service's HAProxy configuration instead of
+// being ignored.
+func (s *ServerTestSuite) Test_ReconfigureFromEvent_RemovesOnRemoved() {
+	var removedName string
+	actions.NewRemove = func(baseData actions.BaseReconfigure, serviceName string) actions.Removable {
+		removedName = serviceName
+		return getRemoveMock("")
+	}
+	srv := Serve{}
+
+	srv.reconfigureFromEvent(discovery.ProviderID{Type: discovery.EventRemoved, Service: proxy.Service{ServiceName: "svc-a"}})
+
+	s.Equal("svc-a", removedName)
+}
+
 func (s *ServerTestSuite) Test_Execute_InvokesReloadAllServicesWithListenerAddress() {
 	listenerAddress := "swarm-listener"
 	mockObj := getReconfigureMock("")
@@ -287,6 +384,54 @@ func (s *ServerTestSuite) Test_Execute_AddsHttpToConsulAddresses() {
 	s.Equal(expected, srv.ConsulAddresses)
 }
 
+// ServeHTTP > Reconfigure / Remove
+
+// Test_ServeHTTP_InvokesReconfigureHandler_WhenUrlIsReconfigure asserts
+// that requests to the reconfigure URL (the one the test suite itself
+// builds in RequestReconfigure) actually reach the reconfigure action,
+// instead of falling through to the default 404.
+func (s *ServerTestSuite) Test_ServeHTTP_InvokesReconfigureHandler_WhenUrlIsReconfigure() {
+	mockObj := getReconfigureMock("")
+	actions.NewReconfigure = func(baseData actions.BaseReconfigure, serviceData proxy.Service, mode string) actions.Reconfigurable {
+		return mockObj
+	}
+	srv := Serve{}
+
+	srv.ServeHTTP(s.ResponseWriter, s.RequestReconfigure)
+
+	mockObj.AssertCalled(s.T(), "Execute", []string{})
+}
+
+// Test_ServeHTTP_InvokesRemoveHandler_WhenUrlIsRemove asserts that
+// requests to the remove URL actually reach the remove action.
+func (s *ServerTestSuite) Test_ServeHTTP_InvokesRemoveHandler_WhenUrlIsRemove() {
+	mockObj := getRemoveMock("")
+	var removedName string
+	actions.NewRemove = func(baseData actions.BaseReconfigure, serviceName string) actions.Removable {
+		removedName = serviceName
+		return mockObj
+	}
+	srv := Serve{}
+
+	srv.ServeHTTP(s.ResponseWriter, s.RequestRemove)
+
+	mockObj.AssertCalled(s.T(), "Execute", []string{})
+	s.Equal(s.ServiceName, removedName)
+}
+
+// ReconfigureUrl > IP filtering
+
+func (s *ServerTestSuite) Test_ReconfigureUrl_ParsesOnlyFromAndDenyFromIPsIntoGeneratedBackend() {
+	svc := actions.ServiceFromQuery(s.RequestReconfigure.URL.Query())
+	r := actions.Reconfigure{}
+
+	_, back, err := r.GetTemplates(&svc)
+
+	s.NoError(err)
+	s.Contains(back, "acl allowed_src src 10.0.0.0/8 192.168.1.0/24")
+	s.Contains(back, "acl denied_src src 1.2.3.4/32")
+}
+
 // CertPutHandler
 
 func (s *ServerTestSuite) Test_CertPutHandler_InvokesCertPut_WhenUrlIsCert() {
@@ -415,6 +560,20 @@ func TestServerUnitTestSuite(t *testing.T) {
 
 // Mock
 
+type providerMock struct {
+	services []proxy.Service
+}
+
+func (m *providerMock) List() ([]proxy.Service, error) {
+	return m.services, nil
+}
+
+func (m *providerMock) Events(ctx context.Context) <-chan discovery.ProviderID {
+	out := make(chan discovery.ProviderID)
+	close(out)
+	return out
+}
+
 type ServerMock struct {
 	mock.Mock
 }
@@ -462,10 +621,13 @@ func getResponseWriterMock() *ResponseWriterMock {
 }
 
 type CertMock struct {
-	PutMock     func(http.ResponseWriter, *http.Request) (string, error)
-	PutCertMock func(certName string, certContent []byte) (string, error)
-	GetAllMock  func(w http.ResponseWriter, req *http.Request) (server.CertResponse, error)
-	GetInitMock func() error
+	PutMock         func(http.ResponseWriter, *http.Request) (string, error)
+	PutCertMock     func(certName string, certContent []byte) (string, error)
+	GetAllMock      func(w http.ResponseWriter, req *http.Request) (server.CertResponse, error)
+	GetInitMock     func() error
+	EnsureACMEMock  func(domains []string) error
+	HTTPHandlerMock func(fallback http.Handler) http.Handler
+	SetOnRenewMock  func(onRenew func() error)
 }
 
 func (m CertMock) Put(w http.ResponseWriter, req *http.Request) (string, error) {
@@ -484,6 +646,26 @@ func (m CertMock) Init() error {
 	return m.GetInitMock()
 }
 
+func (m CertMock) EnsureACME(domains []string) error {
+	if m.EnsureACMEMock == nil {
+		return nil
+	}
+	return m.EnsureACMEMock(domains)
+}
+
+func (m CertMock) HTTPHandler(fallback http.Handler) http.Handler {
+	if m.HTTPHandlerMock == nil {
+		return fallback
+	}
+	return m.HTTPHandlerMock(fallback)
+}
+
+func (m CertMock) SetOnRenew(onRenew func() error) {
+	if m.SetOnRenewMock != nil {
+		m.SetOnRenewMock(onRenew)
+	}
+}
+
 type ReloadMock struct {
 	ExecuteMock func(recreate bool, listenerAddr string) error
 }
@@ -533,6 +715,23 @@ func (m *ReconfigureMock) GetTemplates(sr *proxy.Service) (front, back string, e
 	return params.String(0), params.String(1), params.Error(2)
 }
 
+type RemoveMock struct {
+	mock.Mock
+}
+
+func (m *RemoveMock) Execute(args []string) error {
+	params := m.Called(args)
+	return params.Error(0)
+}
+
+func getRemoveMock(skipMethod string) *RemoveMock {
+	mockObj := new(RemoveMock)
+	if skipMethod != "Execute" {
+		mockObj.On("Execute", mock.Anything).Return(nil)
+	}
+	return mockObj
+}
+
 func getReconfigureMock(skipMethod string) *ReconfigureMock {
 	mockObj := new(ReconfigureMock)
 	if skipMethod != "Execute" {